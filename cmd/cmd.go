@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/mvksxm/firestore-json-convert/cmd/commands"
+	"github.com/mvksxm/firestore-json-convert/engine"
 	"github.com/spf13/cobra"
 )
 
@@ -10,9 +13,24 @@ const (
 	rootCmdDescription = "CLI tool for converting JSON files to the Firestore API compatible ones and the other way around."
 )
 
+// Exit codes of the CLI.
+const (
+	// All the files were converted.
+	ExitOK = 0
+	// Some of the files were not converted.
+	ExitPartialFailure = 1
+	// None of the files were converted.
+	ExitFailure = 2
+	// Flags, arguments or options provided are invalid.
+	ExitUsage = 3
+)
+
 var RootCmd = &cobra.Command{
 	Use:   "fic",
 	Short: rootCmdDescription,
+	// Errors are printed by the caller, alongside the respective exit code.
+	SilenceErrors: true,
+	SilenceUsage: true,
 	// Long: `Will be added later.`
 }
 
@@ -22,8 +40,25 @@ func init() {
 	// Register commands
 	previewCmd := commands.NewPreviewCommand().GetCommand()
 	generateCmd := commands.NewGenerateCommand().GetCommand()
+	inferSchemaCmd := commands.NewInferSchemaCommand().GetCommand()
+	patchCmd := commands.NewPatchCommand().GetCommand()
 
 
 	// Add commands to the root cmd
-	RootCmd.AddCommand(previewCmd, generateCmd)
-}
\ No newline at end of file
+	RootCmd.AddCommand(previewCmd, generateCmd, inferSchemaCmd, patchCmd)
+}
+
+// Maps the error of the command to the exit code. Errors, that are not produced by the engine,
+// come from the parsing of the command line (e.g. unknown flags), so they are treated as the usage errors.
+func ExitCode(err error) int {
+	var engineErr *engine.Error
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, engine.ErrPartialFailure):
+		return ExitPartialFailure
+	case errors.Is(err, engine.ErrInvalidUsage) || !errors.As(err, &engineErr):
+		return ExitUsage
+	}
+	return ExitFailure
+}
@@ -1,14 +1,26 @@
 package commands
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/mvksxm/firestore-json-convert/engine"
+	"github.com/mvksxm/firestore-json-convert/utils"
+	"github.com/spf13/cobra"
+)
 
 type BaseCommand struct {
 	command cobra.Command
 	// payload string
-	file string
+	files []string
+	schemaPath string
+	reportFormat string
+	reportPath string
+	failFast bool
+	keepGoing bool
+	opts engine.Options
 }
 
-func (bc *BaseCommand) generateArrays() []string {
+// Returns the input paths - the ones provided with the '-f' flag, followed by the positional arguments.
+// Input path '-' stands for the standard input.
+func (bc *BaseCommand) generateArrays(args []string) []string {
 
 	// var payloadArr []string = nil
 	var fileArr []string = nil
@@ -17,29 +29,113 @@ func (bc *BaseCommand) generateArrays() []string {
 	// 	payloadArr = []string {bc.payload}
 	// }
 
-	if bc.file != "" {
-		fileArr = []string {bc.file}
+	if len(bc.files) > 0 {
+		fileArr = append(fileArr, bc.files...)
+	}
+
+	if len(args) > 0 {
+		fileArr = append(fileArr, args...)
+	}
+
+	// Piped payload is read, in case, if no input paths are provided.
+	if len(fileArr) == 0 && utils.IsStdinPiped() {
+		fileArr = []string {utils.StdStreamPath}
 	}
 
 	return fileArr
 }
 
+// Returns the conversion options, populated from the CLI flags.
+func (bc *BaseCommand) buildOptions() (engine.Options, error) {
+	opts := bc.opts
+
+	if bc.schemaPath != "" {
+		schema, err := engine.LoadSchema(bc.schemaPath)
+		if err != nil {
+			return opts, err
+		}
+		opts.Schema = schema
+	}
+
+	return opts, nil
+}
+
 func (bc *BaseCommand) Init(
 	name string, 
 	shortDesc string,
 	// longDesc string, 
-	runFunc func(cmd *cobra.Command, args []string),
+	runFunc func(cmd *cobra.Command, args []string) error,
 ) {
 
 	// Populate basic args of the command.
 	bc.command.Use = name
 	bc.command.Short = shortDesc
 	// bc.c.Long = longDesc	
-	bc.command.Run = runFunc	
+	bc.command.RunE = runFunc
+	bc.opts = engine.DefaultOptions()
 
 	// Global CLI args
 	// bc.command.Flags().StringVarP(&bc.payload, "payload", "p", "", "Specify inline json payload to be converted.")
-	bc.command.Flags().StringVarP(&bc.file, "file", "f", "", "Specify path to the file that contain json structure to be converted.")
+	bc.command.Flags().StringSliceVarP(&bc.files, "file", "f", nil, "Specify paths to the files that contain json structures to be converted (repeatable). '-' stands for stdin, which is also read, if no files are specified and the payload is piped.")
+	bc.command.Flags().BoolVar(&bc.opts.PreserveNumberTypes, "preserve-number-types", bc.opts.PreserveNumberTypes, "Keep the integer/double distinction, so the decoded payload is encoded back into the same Firestore types (e.g. 3.0 stays a double).")
+	bc.command.Flags().StringVar(&bc.schemaPath, "schema", "", `Specify path to the schema file ({"fields": {"profile.age": "integer"}}), the payloads should be encoded according to.`)
+	bc.command.Flags().StringVar(&bc.opts.NameKey, "name-key", bc.opts.NameKey, "Key of the plain JSON, the full document name is decoded into and encoded from.")
+	bc.command.Flags().StringVar(&bc.opts.IDKey, "id-key", bc.opts.IDKey, "Key of the plain JSON, the document ID is decoded into and encoded from (requires --collection for encoding).")
+	bc.command.Flags().StringVar(&bc.opts.CreateTimeKey, "create-time-key", bc.opts.CreateTimeKey, "Key of the plain JSON, the document 'createTime' is decoded into and encoded from.")
+	bc.command.Flags().StringVar(&bc.opts.UpdateTimeKey, "update-time-key", bc.opts.UpdateTimeKey, "Key of the plain JSON, the document 'updateTime' is decoded into and encoded from.")
+	bc.command.Flags().StringVar(&bc.opts.CollectionPath, "collection", bc.opts.CollectionPath, "Specify path of the collection the documents belong to (projects/{project_id}/databases/{database_id}/documents/{collection_path}).")
+	bc.command.Flags().BoolVar(&bc.opts.CollectErrors, "collect-errors", bc.opts.CollectErrors, "Walk the whole payload and report the errors of all the invalid paths, instead of failing on the first one.")
+	bc.command.Flags().IntVar(&bc.opts.MaxErrors, "max-errors", bc.opts.MaxErrors, "Maximum amount of the errors, reported for a single payload with --collect-errors (0 - unlimited).")
+}
+
+// Registers the flags of the files conversion - its direction, representation of the decoded values and the generated
+// request bodies. Used by the commands, that convert the files with the MultipleConverter.
+func (bc *BaseCommand) initConversionFlags() {
+	bc.command.Flags().StringVar((*string)(&bc.opts.Direction), "direction", string(bc.opts.Direction), "Direction of the conversion: 'encode' (plain JSON -> Firestore), 'decode' (Firestore -> plain JSON) or 'auto' (detected from the structure of each payload and reported).")
+	bc.command.Flags().BoolVar(&bc.opts.TagVectors, "tag-vectors", bc.opts.TagVectors, `Decode Firestore vectors into the {"$vector": [...]} form, so they can be encoded back.`)
+	bc.command.Flags().StringVar((*string)(&bc.opts.SpecialDoubles), "special-doubles", string(bc.opts.SpecialDoubles), `Representation of the NaN/Infinity/-Infinity doubles in the decoded JSON: 'tagged' ({"$double": "NaN"}), 'string' or 'null'.`)
+	bc.command.Flags().BoolVar(&bc.opts.EmitTypeHints, "type-hints", bc.opts.EmitTypeHints, `Wrap decoded values, which type can't be guessed from the plain JSON, into type hints (e.g. {"$string": "abcd="}).`)
+	bc.command.Flags().StringVar((*string)(&bc.opts.CollectionFormat), "collection-format", string(bc.opts.CollectionFormat), "Representation of the decoded document collections (e.g. 'runQuery' responses): 'map' (keyed by document ID) or 'array'.")
+	bc.command.Flags().StringVar((*string)(&bc.opts.WriteBody), "write-body", string(bc.opts.WriteBody), "Encode the plain JSON collection ({\"{document_id}\": {...}}) into the 'commit' or 'batchWrite' request bodies (requires --collection). Bodies are split by 500 writes.")
+	bc.command.Flags().StringVar((*string)(&bc.opts.Precondition), "precondition", string(bc.opts.Precondition), "'currentDocument' precondition of the generated writes: 'none', 'exists' or 'missing'.")
+	bc.command.Flags().StringVar((*string)(&bc.opts.UpdateMask), "update-mask", string(bc.opts.UpdateMask), "'updateMask' of the generated writes: 'fields' (every write updates the provided fields only, the rest of the document is kept) or 'none' (the whole document is replaced).")
+	bc.command.Flags().IntVar(&bc.opts.MaxDocumentSize, "max-size", bc.opts.MaxDocumentSize, "Limit of the estimated size of the encoded documents in bytes (Firestore limit is 1 MiB).")
+	bc.command.Flags().StringVar((*string)(&bc.opts.Oversize), "oversize", string(bc.opts.Oversize), "Action, taken in generate mode, when the document exceeds the --max-size limit: 'warn' (output is still written) or 'fail'. Preview only reports the size.")
+}
+
+// Registers the flags of the run - its report and the handling of the failed files. Used by the commands,
+// that convert the files with the MultipleConverter.
+func (bc *BaseCommand) initRunFlags() {
+	bc.command.Flags().StringVar(&bc.reportFormat, "report", string(engine.ReportText), "Format of the run report: 'text' (logs only) or 'json' (single structured report with the outcome of each file and the summary).")
+	bc.command.Flags().StringVar(&bc.reportPath, "report-file", "", "Specify path of the json report (--report json). Report is printed to stdout, in case, if it's not specified.")
+	bc.command.Flags().BoolVar(&bc.failFast, "fail-fast", false, "Stop the run at the first file, that can't be converted. The rest of the files are reported as skipped.")
+	bc.command.Flags().BoolVar(&bc.keepGoing, "keep-going", true, "Convert the rest of the files, in case, if some of them can't be converted (default). '--keep-going=false' is the same as '--fail-fast'.")
+}
+
+// Returns, whether the run should be stopped at the first failed file. Flags are the opposites of each other,
+// so they can't be set to the same value.
+func (bc *BaseCommand) isFailFast() (bool, error) {
+	flags := bc.command.Flags()
+	if flags.Changed("fail-fast") && flags.Changed("keep-going") && bc.failFast == bc.keepGoing {
+		return false, &engine.Error{Kind: engine.ErrInvalidUsage, Reason: "'--fail-fast' and '--keep-going' flags contradict each other."}
+	}
+	return bc.failFast || !bc.keepGoing, nil
+}
+
+// Passes the run flags to the converter and runs it.
+func (bc *BaseCommand) runConverter(c *engine.MultipleConverter) error {
+	opts, err := bc.buildOptions()
+	if err != nil {
+		return err
+	}
+	failFast, err := bc.isFailFast()
+	if err != nil {
+		return err
+	}
+	c.SetOptions(opts)
+	c.SetReport(engine.ReportFormat(bc.reportFormat), bc.reportPath)
+	c.SetFailFast(failFast)
+	return c.Run()
 }
 
 func (bc *BaseCommand) GetCommand() *cobra.Command {
@@ -3,4 +3,6 @@ package commands
 const (
 	previewCmdDescription = "Preview the changes that will be applied to the json structures provided."
 	generateCmdDescription = "Apply the transformations to the json structures provided."
+	patchCmdDescription = "Generate the Firestore PATCH body (changed fields and update mask) from the old and the new versions of the json document (-f)."
+	inferSchemaCmdDescription = "Infer the union schema (field paths, types and optionality) of the json structures provided."
 )
@@ -2,26 +2,66 @@ package commands
 
 import (
 	"github.com/mvksxm/firestore-json-convert/engine"
+	"github.com/mvksxm/firestore-json-convert/utils"
 	"github.com/spf13/cobra"
 )
 
 type GenerateCommand struct {
 	BaseCommand
-	outputPath string
+	outputPaths []string
+	mappingPath string
+	merge bool
+	include []string
+	exclude []string
 }
 
-func (gc *GenerateCommand) run(_ *cobra.Command, _ []string) {
+// Checks, whether any of the input paths is a directory or a glob pattern, so the output path is a directory.
+func isTreeInput(fileArr []string) bool {
+	for _, path := range fileArr {
+		if utils.IsDir(path) || (utils.IsGlobPattern(path) && !utils.IsFile(path)) {
+			return true
+		}
+	}
+	return false
+}
 
-	fileArr := gc.generateArrays()
-	var outputArr []string = nil
+func (gc *GenerateCommand) run(_ *cobra.Command, args []string) error {
 
-	if gc.outputPath != "" {
-		outputArr = []string {gc.outputPath}
+	outputArr := gc.outputPaths
+
+	// Pairs of the mapping file go after the ones, provided with the '-f' and '-o' flags.
+	if gc.mappingPath != "" {
+		mappedInputs, mappedOutputs, err := engine.LoadPathMapping(gc.mappingPath)
+		if err != nil {
+			return err
+		}
+		args = append(args, mappedInputs...)
+		outputArr = append(outputArr, mappedOutputs...)
+	}
+
+	fileArr := gc.generateArrays(args)
+
+	// Single output is written to the standard output, in case, if no output path is provided.
+	if len(outputArr) == 0 && (len(fileArr) == 1 || gc.merge) {
+		outputArr = []string {utils.StdStreamPath}
 	}
 
 	c := engine.NewMultipleConverter(fileArr, outputArr)
+	if gc.merge || isTreeInput(fileArr) {
+		if len(outputArr) != 1 {
+			return &engine.Error{
+				Kind: engine.ErrInvalidUsage,
+				Reason: "In merge mode ('--merge' CLI flag) or in case, if directories or glob patterns are provided, exactly one output path (-o CLI flag) should be specified!",
+			}
+		}
+		if gc.merge {
+			c = engine.NewMultipleConverterMerge(fileArr, outputArr[0])
+		} else {
+			c = engine.NewMultipleConverterTree(fileArr, outputArr[0], gc.include, gc.exclude)
+		}
+	}
 
-	c.Run()
+	return gc.runConverter(c)
 } 
 
 func (gc *GenerateCommand) Init() {
@@ -31,8 +71,14 @@ func (gc *GenerateCommand) Init() {
 		generateCmdDescription,
 		gc.run,
 	)
+	gc.initConversionFlags()
+	gc.initRunFlags()
 
-	gc.command.Flags().StringVarP(&gc.outputPath, "output", "o", "", "Specify output file path.")
+	gc.command.Flags().StringSliceVarP(&gc.outputPaths, "output", "o", nil, "Specify output file paths (repeatable), paired with the input paths in the order provided. '-' stands for stdout, which is also used, if a single input is converted without the output path. In case, if directories or glob patterns are provided, it's the output directory, that mirrors the input tree.")
+	gc.command.Flags().StringVar(&gc.mappingPath, "map", "", `Specify path to the mapping file of the input and output paths ({"input.json": "output.json"}). Relative paths are resolved against the directory of the mapping file.`)
+	gc.command.Flags().BoolVar(&gc.merge, "merge", false, "Decode the documents of all the input files ('runQuery', 'batchGet' or paginated 'listDocuments' responses) into the single output file.")
+	gc.command.Flags().StringSliceVar(&gc.include, "include", nil, "Patterns of the files, that are converted from the input directories and glob patterns (default '*.json'). Patterns with a slash are matched against the relative path.")
+	gc.command.Flags().StringSliceVar(&gc.exclude, "exclude", nil, "Patterns of the files, that are skipped in the input directories and glob patterns. Patterns with a slash are matched against the relative path.")
 }
 
 func NewGenerateCommand() *GenerateCommand {
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mvksxm/firestore-json-convert/engine"
+	"github.com/mvksxm/firestore-json-convert/utils"
+	"github.com/spf13/cobra"
+)
+
+type InferSchemaCommand struct {
+	command cobra.Command
+	files []string
+	outputPath string
+	manifest bool
+}
+
+func (ic *InferSchemaCommand) run(_ *cobra.Command, args []string) error {
+
+	inputPaths := append(ic.files, args...)
+	if len(inputPaths) == 0 && utils.IsStdinPiped() {
+		inputPaths = []string{utils.StdStreamPath}
+	}
+	if len(inputPaths) == 0 {
+		return &engine.Error{Kind: engine.ErrInvalidUsage, Reason: "Input paths (-f CLI flag or positional arguments) can't be empty!"}
+	}
+
+	inferredSchema, err := engine.InferSchema(inputPaths)
+	if err != nil {
+		return err
+	}
+
+	var result interface{} = inferredSchema
+	if ic.manifest {
+		result = map[string]interface{}{"fields": inferredSchema.Manifest()}
+	}
+
+	resultStr, err := json.MarshalIndent(result, "", "    ")
+	if err != nil {
+		return &engine.Error{Kind: engine.ErrInvalidPayload, Reason: "The following error had occured, when jsonifying the inferred schema - " + err.Error(), Err: err}
+	}
+
+	if ic.outputPath == "" {
+		fmt.Println(string(resultStr))
+		return nil
+	}
+
+	if err := os.WriteFile(ic.outputPath, resultStr, 0777); err != nil {
+		return &engine.Error{Kind: engine.ErrIO, File: ic.outputPath, Reason: "There was an issue with writing the inferred schema to the output file. Err - " + err.Error(), Err: err}
+	}
+	return nil
+}
+
+func (ic *InferSchemaCommand) Init() {
+	ic.command.Use = "infer-schema [files...]"
+	ic.command.Short = inferSchemaCmdDescription
+	ic.command.RunE = ic.run
+
+	ic.command.Flags().StringSliceVarP(&ic.files, "file", "f", nil, "Specify paths to the files, which documents the schema should be inferred from.")
+	ic.command.Flags().StringVarP(&ic.outputPath, "output", "o", "", "Specify output file path. Schema is printed, in case, if it's not specified.")
+	ic.command.Flags().BoolVar(&ic.manifest, "manifest", false, "Output the schema manifest, that could be passed to the '--schema' flag, instead of the field statistics.")
+}
+
+func (ic *InferSchemaCommand) GetCommand() *cobra.Command {
+	return &ic.command
+}
+
+func NewInferSchemaCommand() *InferSchemaCommand {
+	ic := new(InferSchemaCommand)
+	ic.Init()
+	return ic
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mvksxm/firestore-json-convert/engine"
+	"github.com/spf13/cobra"
+)
+
+type PatchCommand struct {
+	BaseCommand
+	oldPath string
+	outputPath string
+}
+
+func readDocument(path string) (map[string]interface{}, error) {
+	payload, err := engine.NewFileIO(path, "").ReadInput()
+	if err != nil {
+		return nil, err
+	}
+
+	doc, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, &engine.Error{Kind: engine.ErrInvalidPayload, File: path, Reason: "File does not contain a single json object."}
+	}
+
+	return doc, nil
+}
+
+func (pc *PatchCommand) run(_ *cobra.Command, args []string) error {
+
+	fileArr := pc.generateArrays(args)
+	if pc.oldPath == "" || len(fileArr) != 1 {
+		return &engine.Error{Kind: engine.ErrInvalidUsage, Reason: "Exactly one old document (--old CLI flag) and one new document (-f CLI flag) should be specified!"}
+	}
+
+	opts, err := pc.buildOptions()
+	if err != nil {
+		return err
+	}
+
+	oldDoc, err := readDocument(pc.oldPath)
+	if err != nil {
+		return err
+	}
+	newDoc, err := readDocument(fileArr[0])
+	if err != nil {
+		return err
+	}
+
+	patchBody, err := engine.BuildPatchBody(oldDoc, newDoc, opts)
+	if err != nil {
+		return fmt.Errorf("Patch body can't be generated, due to the following reason - %w", err)
+	}
+
+	if pc.outputPath != "" {
+		return engine.NewFileIO(fileArr[0], pc.outputPath).WriteOutput(patchBody)
+	}
+
+	patchBodyStr, err := json.MarshalIndent(patchBody, "", "    ")
+	if err != nil {
+		return &engine.Error{Kind: engine.ErrInvalidPayload, Reason: "The following error had occured, when jsonifying the patch body - " + err.Error(), Err: err}
+	}
+	fmt.Println(string(patchBodyStr))
+	return nil
+}
+
+func (pc *PatchCommand) Init() {
+
+	pc.BaseCommand.Init(
+		"patch",
+		patchCmdDescription,
+		pc.run,
+	)
+
+	pc.command.Flags().StringVar(&pc.oldPath, "old", "", "Specify path to the file that contain the old version of the document.")
+	pc.command.Flags().StringVarP(&pc.outputPath, "output", "o", "", "Specify output file path. Patch body is printed, in case, if it's not specified.")
+}
+
+func NewPatchCommand() *PatchCommand {
+	pc := new(PatchCommand)
+	pc.Init()
+	return pc
+}
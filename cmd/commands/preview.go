@@ -10,10 +10,9 @@ type PreviewCommand struct {
 	BaseCommand
 }
 
-func (pc *PreviewCommand) run(_ *cobra.Command, _ []string) {
-	fileArr := pc.generateArrays()
-	c := engine.NewMultipleConverterPreview(fileArr)
-	c.Run()
+func (pc *PreviewCommand) run(_ *cobra.Command, args []string) error {
+	fileArr := pc.generateArrays(args)
+	return pc.runConverter(engine.NewMultipleConverterPreview(fileArr))
 } 
 
 func (pc *PreviewCommand) Init() {
@@ -22,6 +21,8 @@ func (pc *PreviewCommand) Init() {
 		previewCmdDescription,
 		pc.run,
 	)
+	pc.initConversionFlags()
+	pc.initRunFlags()
 }
 
 func NewPreviewCommand() *PreviewCommand {
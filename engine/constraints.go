@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+const (
+	// Maximum depth of the maps and arrays of the Firestore document. Each map and array adds one level.
+	maxFieldDepth = 20
+	// Maximum size of the field name in bytes.
+	maxFieldNameBytes = 1500
+)
+
+var (
+	// Field names, that are reserved by Firestore - '__name__', '__key__', ...
+	reservedFieldNameRegex = regexp.MustCompile(`^__.*__$`)
+)
+
+type constraintViolation struct {
+	path string
+	typeKey string
+	reason string
+}
+
+func checkFieldName(key string, path string, violations *[]constraintViolation) {
+	switch {
+	case key == "":
+		*violations = append(*violations, constraintViolation{path, "mapValue", "Field name can't be empty."})
+	case reservedFieldNameRegex.MatchString(key):
+		*violations = append(*violations, constraintViolation{path, "mapValue", fmt.Sprintf("Field name '%s' is reserved by Firestore.", key)})
+	case len(key) > maxFieldNameBytes:
+		*violations = append(*violations, constraintViolation{
+			path, "mapValue", fmt.Sprintf("Field name is %d bytes long, while the maximum is %d bytes.", len(key), maxFieldNameBytes),
+		})
+	}
+}
+
+// Collects the violations of the Firestore data model constraints under the plain JSON value provided.
+// Tagged values are opaque, except for the content of the '$map' type hint, which is a regular map.
+func collectViolations(payloadVal interface{}, path string, depth int, violations *[]constraintViolation) {
+	switch t := payloadVal.(type) {
+	case []interface{}:
+		if depth >= maxFieldDepth && len(t) > 0 {
+			*violations = append(*violations, constraintViolation{
+				path, "arrayValue", fmt.Sprintf("Array exceeds the maximum depth of the nested maps and arrays - %d.", maxFieldDepth),
+			})
+			return
+		}
+		for i, elem := range t {
+			elemPath := path + fmt.Sprintf("[%d]", i)
+			if _, isArr := elem.([]interface{}); isArr {
+				*violations = append(*violations, constraintViolation{elemPath, "arrayValue", "Arrays can't directly contain other arrays."})
+				continue
+			}
+			collectViolations(elem, elemPath, depth + 1, violations)
+		}
+	case map[string]interface{}:
+		if isTaggedShape(t) {
+			if content, isMap := t[mapTag].(map[string]interface{}); isMap {
+				collectViolations(content, path + "/" + mapTag, depth, violations)
+			}
+			return
+		}
+		if depth >= maxFieldDepth && len(t) > 0 {
+			*violations = append(*violations, constraintViolation{
+				path, "mapValue", fmt.Sprintf("Map exceeds the maximum depth of the nested maps and arrays - %d.", maxFieldDepth),
+			})
+			return
+		}
+		for k, v := range t {
+			childPath := path + "/" + k
+			checkFieldName(k, childPath, violations)
+			collectViolations(v, childPath, depth + 1, violations)
+		}
+	}
+}
+
+// Validates the plain JSON fields against the Firestore data model constraints (nested arrays, depth of the maps,
+// reserved and oversized field names), so the payload is not rejected at the write time.
+// All the violations found are reported at once, sorted by their paths.
+func validateConstraints(fields map[string]interface{}) error {
+	violations := []constraintViolation{}
+	for k, v := range fields {
+		checkFieldName(k, k, &violations)
+		collectViolations(v, k, 1, &violations)
+	}
+
+	return joinViolations(violations)
+}
+
+func joinViolations(violations []constraintViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].path < violations[j].path
+	})
+
+	errs := make([]error, 0, len(violations))
+	for _, violation := range violations {
+		errs = append(errs, newError(ErrConstraintViolation, violation.path, violation.typeKey, violation.reason))
+	}
+	return errors.Join(errs...)
+}
@@ -2,12 +2,13 @@ package engine
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 	"github.com/mvksxm/firestore-json-convert/models"
 	"github.com/mvksxm/firestore-json-convert/utils"
 )
@@ -15,12 +16,17 @@ import (
 type Converter struct {
 	isPreview bool
 	fileIO FileIO
+	opts Options
+	// Reason, the file was not converted for. Nil, if the conversion succeeded.
+	err error
+	report *FileReport
 }
 
-func NewConverter(isPreview bool, fileIO FileIO) *Converter {
+func NewConverter(isPreview bool, fileIO FileIO, opts Options) *Converter {
 	return &Converter{
 		isPreview: isPreview,
 		fileIO: fileIO,
+		opts: opts,
 	}
 }
 
@@ -28,38 +34,130 @@ func (c *Converter) Run(wg *sync.WaitGroup) {
 
 	defer wg.Done()
 
+	start := time.Now()
+	c.report = newFileReport(c.fileIO.GetInputPath(), c.fileIO.GetOutputPath())
+	c.err = c.run()
+	c.report.finish(c.err, time.Since(start))
+}
+
+// Returns the error, the file was not converted for. Nil, if the conversion succeeded.
+func (c *Converter) Err() error {
+	return c.err
+}
+
+// Returns the report of the last run. Nil, if the converter was not run.
+func (c *Converter) Report() *FileReport {
+	return c.report
+}
+
+func (c *Converter) run() error {
+
 	payload, err := c.fileIO.ReadInput()
 	if err != nil {
-		log.Fatalln(err)
+		c.report.skip(err)
+		return err
 	}
 
-	prc := NewProcessor(payload)
-	processedPayload, err := prc.Convert()
+	prc := NewProcessorWithOptions(payload, c.opts)
+	defer func() {
+		c.report.Direction, c.report.DirectionReason = prc.Direction()
+		c.report.Documents = prc.Documents()
+		c.report.Missing = prc.MissingDocuments()
+	}()
+
+	var processedPayload interface{}
+	var writeBodies []interface{}
+	if c.opts.WriteBody != WriteBodyNone {
+		writeBodies, err = prc.ConvertToWriteBodies()
+		processedPayload = writeBodies
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Payload of the file - %s can't be converted into the '%s' request body. Reason - %s", c.fileIO.GetInputPath(), c.opts.WriteBody, err.Error()))
+		}
+	} else {
+		processedPayload, err = prc.Convert()
+		direction, reason := prc.Direction()
+		if c.opts.Direction == DirectionAuto {
+			slog.Info(fmt.Sprintf("Payload of the file - %s is %sd, since %s.", c.fileIO.GetInputPath(), direction, reason))
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("Payload of the file - %s can't be %sd. Reason - %s", c.fileIO.GetInputPath(), direction, err.Error()))
+		}
+	}
 
 	if err != nil {
-		return
+		return err
 	}	
 
+	for _, size := range prc.DocumentSizes() {
+		sizeErr := size.exceeds(c.opts.MaxDocumentSize)
+		if sizeErr == nil {
+			continue
+		}
+		// In preview, the limit is only reported, so the oversized documents could still be inspected.
+		if !c.isPreview && c.opts.Oversize == OversizeFail {
+			slog.Warn(fmt.Sprintf("Output of the file - %s won't be written. Reason - %s", c.fileIO.GetInputPath(), sizeErr.Error()))
+			return sizeErr
+		}
+		slog.Warn(fmt.Sprintf("File - %s: %s", c.fileIO.GetInputPath(), sizeErr.Error()))
+	}
+
 	if c.isPreview {
 		payloadStr, err := json.MarshalIndent(processedPayload, "", "    ")
 		if err != nil {
 			slog.Warn(fmt.Sprintf("The following error had occured, when jsonifying the processed payload -  %s", err.Error()))
-		} else {
-			fmt.Println("====================================================================================")
-			fmt.Printf("Preview for file -> %s\n", c.fileIO.GetInputPath())
-			fmt.Println(string(payloadStr))
-			fmt.Println("====================================================================================")
+			return err
+		}
+		c.printPreview(string(payloadStr), prc.DocumentSizes())
+		return nil
+	}
+
+	if writeBodies != nil {
+		return c.fileIO.WriteOutputChunks(writeBodies)
+	}
+
+	return c.fileIO.WriteOutput(processedPayload)
+}
+
+// Prints the processed payload. Payload of the standard input is printed without the separators and the sizes,
+// so the output is a pure json, that could be piped further.
+func (c *Converter) printPreview(payloadStr string, sizes []*DocumentSize) {
+	if c.fileIO.IsStdin() {
+		fmt.Println(payloadStr)
+		for _, size := range sizes {
+			slog.Info(fmt.Sprintf("Estimated size of the document -> %s: %d bytes", size.displayName(), size.Bytes))
 		}
 		return
 	}
 
-	c.fileIO.WriteOutput(processedPayload)
+	fmt.Println("====================================================================================")
+	fmt.Printf("Preview for file -> %s\n", c.fileIO.GetInputPath())
+	fmt.Println(payloadStr)
+	for _, size := range sizes {
+		fmt.Printf("Estimated size of the document -> %s: %d bytes\n", size.displayName(), size.Bytes)
+	}
+	fmt.Println("====================================================================================")
 }
 
 type MultipleConverter struct {
 	isPreview bool
+	// In merge mode, documents of all the input responses are decoded into a single output file.
+	isMerge bool
+	// In tree mode, input directories and glob patterns are expanded into the files, which outputs are written
+	// into the single output directory, that mirrors the input tree.
+	isTree bool
+	include []string
+	exclude []string
 	inputPaths []string
 	outputPaths []string
+	opts Options
+	// Amount of the files, that were filtered out or skipped, due to their invalid paths.
+	skipped int
+	// In fail-fast mode, the run is stopped at the first failed file, instead of converting the rest of the files.
+	failFast bool
+	reportFormat ReportFormat
+	// Path of the json report. Report is written to the standard output, in case, if it's empty.
+	reportPath string
+	report *RunReport
 } 
 
 func (mc *MultipleConverter) initValMap(valChannel chan models.StampedPath) map[int][]models.StampedPath {
@@ -86,7 +184,7 @@ func (mc *MultipleConverter) checkPathDuplicates() error {
 	}
 
 	if len(dSet) != len(mc.inputPaths) {
-		return errors.New("Input paths (CLI arg '-f') are not unique.")
+		return newError(ErrInvalidUsage, "", "", "Input paths (CLI arg '-f') are not unique.")
 	}
 
 	clear(dSet)
@@ -96,7 +194,7 @@ func (mc *MultipleConverter) checkPathDuplicates() error {
 	}
 
 	if len(dSet) != len(mc.outputPaths) {
-		return errors.New("Output paths (CLI arg '-o') are not unique.")
+		return newError(ErrInvalidUsage, "", "", "Output paths (CLI arg '-o') are not unique.")
 	}
 
 	return nil
@@ -105,19 +203,60 @@ func (mc *MultipleConverter) checkPathDuplicates() error {
 func (mc *MultipleConverter) validate() error {
 
 	if mc.inputPaths == nil {
-		return errors.New("Input paths (-f CLI flag) can't be empty!")
+		return newError(ErrInvalidUsage, "", "", "Input paths (-f CLI flag) can't be empty!")
+	}
+
+	if err := mc.reportFormat.validate(); err != nil {
+		return err
+	}
+
+	if mc.reportFormat == ReportJSON && (mc.reportPath == "" || utils.IsStdStream(mc.reportPath)) && mc.writesStdout() {
+		return newError(ErrInvalidUsage, "", "", "Json report can't be written to the standard output, since the converted payloads are written there. Specify the report file path (--report-file CLI flag).")
+	}
+
+	if mc.isTree {
+		if len(mc.outputPaths) != 1 {
+			return newError(ErrInvalidUsage, "", "", "In case, if directories or glob patterns are provided, exactly one output directory (-o CLI flag) should be specified!")
+		}
+		it, err := expandInputTree(mc.inputPaths, mc.outputPaths[0], mc.include, mc.exclude)
+		if err != nil {
+			return err
+		}
+		mc.inputPaths = it.inputPaths
+		mc.outputPaths = it.outputPaths
+		mc.skipped += it.filtered
+	}
+
+	if optsErr := mc.opts.validate(); optsErr != nil {
+		return optsErr
 	}
 
 	if !mc.isPreview && len(mc.outputPaths) == 0 {
-		return errors.New("In case, if mode is 'generate' ('generate' CLI argument), output file path (-o CLI flag) should be specified!")
+		return newError(ErrInvalidUsage, "", "", "In case, if mode is 'generate' ('generate' CLI argument), output file path (-o CLI flag) should be specified!")
 	}
 
 	if dpError := mc.checkPathDuplicates(); dpError != nil {
 		return dpError
 	}
 
-	if !mc.isPreview && len(mc.outputPaths) != len(mc.inputPaths) {
-		return errors.New(
+	if mc.isMerge && (len(mc.outputPaths) != 1 || mc.outputPaths[0] == "") {
+		return newError(ErrInvalidUsage, "", "", "In merge mode ('--merge' CLI flag), exactly one output path (-o CLI flag) should be specified!")
+	}
+
+	// Merged responses are always decoded, so the 'encode' direction can't be applied to them.
+	if mc.isMerge && mc.opts.Direction == DirectionEncode {
+		return newError(ErrInvalidUsage, "", "", "In merge mode ('--merge' CLI flag), Firestore API responses are decoded, so the 'encode' direction (--direction CLI flag) can't be set!")
+	}
+
+	if mc.isMerge {
+		if validated, err := utils.ValidatePath(mc.outputPaths[0], false); !validated {
+			return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Output path - %s is invalid. Invalidity reason - %s", mc.outputPaths[0], err))
+		}
+	}
+
+	if mc.isPaired() && len(mc.outputPaths) != len(mc.inputPaths) {
+		return newError(
+			ErrInvalidUsage, "", "",
 			`In generate mode ('generate' CLI argument), amount of input paths (-f CLI flag) should be equal to the amount of output paths (-o CLI flag)`,
 		)
 	}
@@ -129,7 +268,7 @@ func (mc *MultipleConverter) validate() error {
 	wg.Add(1)
 	go utils.ValidatePaths(mc.inputPaths, true, valChannel, wg)
 
-	if !mc.isPreview {
+	if mc.isPaired() {
 		wg.Add(1)
 		go utils.ValidatePaths(mc.outputPaths, false, valChannel, wg)
 	} 
@@ -144,67 +283,253 @@ func (mc *MultipleConverter) validate() error {
 	validOutput := []string{}
 
 	// Iteration through the map to notify, which paths were not validated and populate valid paths, respectively.
-	for idx, spArr := range valMap {
+	// Iteration goes in the order of the input paths, so the order of the merged documents is preserved.
+	for idx := range mc.inputPaths {
 
+		spArr := valMap[idx]
 		iPath := mc.inputPaths[idx]
 
 		var oPath string
-		if !mc.isPreview {
+		if mc.isPaired() {
 			oPath = mc.outputPaths[idx]
 		}
 
 		if len(spArr) > 0 {
+			fr := newFileReport(iPath, oPath)
 			for _, sp := range spArr {
-				fmt.Printf(
+				fmt.Fprintf(
+					os.Stderr,
 					"Following path - %s is invalid and will be skipped alongside its respective pair. Invalidity reason - %s \n", 
 					sp.Path,
 					sp.Error,
 				)
+				fr.skip(newFileError(ErrInvalidUsage, sp.Path, "Path is invalid. Invalidity reason - " + sp.Error, nil))
 			}
+			mc.report.Files = append(mc.report.Files, fr)
 		} else {
 			// Valid block
 			validInput = append(validInput, iPath)
 
-			if !mc.isPreview {
+			if mc.isPaired() {
 				validOutput = append(validOutput, oPath)
 			}
 		}
 	}
 
+
 	if len(validInput) == 0 {
-		return errors.New("All of the file path pairs provided are invalid! Exiting...")
+		return newError(ErrAllFailed, "", "", "All of the file path pairs provided are invalid! Exiting...")
 	}
 
 	mc.inputPaths = validInput
-	mc.outputPaths = validOutput
+	if mc.isPaired() {
+		mc.outputPaths = validOutput
+	}
 
 	return nil
 }  
 
 
-func (mc *MultipleConverter) Run() {
+// Prints the amount of the converted and skipped files.
+func (mc *MultipleConverter) printSummary() {
+	summary := mc.report.Summary
+	fmt.Fprintf(
+		os.Stderr, "Summary: %d file(s) converted, %d file(s) failed, %d file(s) skipped.\n",
+		summary.Converted, summary.Failed, summary.Skipped,
+	)
+}
+
+// Checks, whether the converted payloads are written to the standard output.
+func (mc *MultipleConverter) writesStdout() bool {
+	return mc.isPreview || slices.ContainsFunc(mc.outputPaths, utils.IsStdStream)
+}
+
+// Returns the mode of the run, reported in the json report.
+func (mc *MultipleConverter) mode() string {
+	switch {
+	case mc.isPreview:
+		return "preview"
+	case mc.isMerge:
+		return "merge"
+	case mc.isTree:
+		return "tree"
+	}
+	return "generate"
+}
+
+// Checks, whether each input path has its respective output path.
+func (mc *MultipleConverter) isPaired() bool {
+	return !mc.isPreview && !mc.isMerge
+}
+
+// Returns the output path of the input path under the index provided. Empty, if it's a preview.
+func (mc *MultipleConverter) outputPath(idx int) string {
+	switch {
+	case mc.isPreview:
+		return ""
+	case mc.isMerge:
+		return mc.outputPaths[0]
+	}
+	return mc.outputPaths[idx]
+}
+
+// Reports the files, starting from the index provided, as skipped, since the run was stopped at the first failure.
+func (mc *MultipleConverter) abort(from int) {
+	if from >= len(mc.inputPaths) {
+		return
+	}
+	slog.Warn(fmt.Sprintf("Run is stopped at the first failure (fail-fast). %d file(s) won't be processed.", len(mc.inputPaths) - from))
+	for i := from; i < len(mc.inputPaths); i++ {
+		fr := newFileReport(mc.inputPaths[i], mc.outputPath(i))
+		fr.skip(newFileError(ErrAborted, mc.inputPaths[i], "File was not processed, since the run was stopped at the first failure.", nil))
+		mc.report.Files = append(mc.report.Files, fr)
+	}
+}
+
+func (mc *MultipleConverter) runMerged() error {
+	payloads := []interface{}{}
+	merged := []*FileReport{}
+	for i, inputPath := range mc.inputPaths {
+		start := time.Now()
+		fr := newFileReport(inputPath, mc.outputPaths[0])
+		mc.report.Files = append(mc.report.Files, fr)
+
+		payload, err := NewFileIO(inputPath, "").ReadInput()
+		if err == nil && !isDocumentsResponse(payload) {
+			slog.Warn(fmt.Sprintf("File - %s is not a 'runQuery', 'batchGet' or 'listDocuments' response. It will be skipped.", inputPath))
+			err = newFileError(ErrInvalidPayload, inputPath, "File is not a 'runQuery', 'batchGet' or 'listDocuments' response.", nil)
+		}
+		if err != nil {
+			fr.skip(err)
+			fr.finish(nil, time.Since(start))
+			// Merged output is not written, in case, if any of the files is skipped in fail-fast mode.
+			if mc.failFast {
+				mc.abort(i + 1)
+				return err
+			}
+			continue
+		}
+
+		fr.Direction, fr.DirectionReason = DirectionDecode, "it is merged with the other Firestore API responses"
+		if documents, missing, err := extractResponseDocuments(payload); err == nil {
+			fr.Documents = len(documents)
+			if len(missing) > 0 {
+				fr.Missing = missing
+			}
+		}
+		// Duration of the merged files covers reading only, since their documents are decoded altogether.
+		fr.DurationMs = durationMs(time.Since(start))
+		payloads = append(payloads, payload)
+		merged = append(merged, fr)
+	}
+
+	if len(payloads) == 0 {
+		return newError(ErrInvalidPayload, "", "", "None of the input files contain a Firestore API response, that could be merged! Exiting...")
+	}
+
+	decodedDocs, missing, err := DecodeResponses(payloads, mc.opts)
+	if err == nil {
+		reportMissingDocuments(missing)
+		err = NewFileIO(strings.Join(mc.inputPaths, ", "), mc.outputPaths[0]).WriteOutput(decodedDocs)
+	}
+
+	for _, fr := range merged {
+		fr.Status = FileConverted
+		if err != nil {
+			fr.Status = FileFailed
+		}
+	}
+	return err
+}
+
+func (mc *MultipleConverter) run() error {
 
 	if err := mc.validate(); err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+		return err
+	}
+
+	// Some of the paths are invalid, so none of the files are converted in fail-fast mode.
+	if mc.failFast && len(mc.report.Files) > 0 {
+		mc.abort(0)
+		return nil
+	}
+
+	if mc.isMerge {
+		return mc.runMerged()
 	}
 	
 	convWg := &sync.WaitGroup{}
+	converters := make([]*Converter, 0, len(mc.inputPaths))
 	for i := range mc.inputPaths {
-		inputPath := mc.inputPaths[i]
-		outputPath := ""
-		if !mc.isPreview {
-			outputPath = mc.outputPaths[i]
-		}
-
-		fileIO := NewFileIO(inputPath, outputPath)
-		conv := NewConverter(mc.isPreview, *fileIO)
+		fileIO := NewFileIO(mc.inputPaths[i], mc.outputPath(i))
+		conv := NewConverter(mc.isPreview, *fileIO, mc.opts)
+		converters = append(converters, conv)
 		convWg.Add(1)
-		go conv.Run(convWg)
+		if !mc.failFast {
+			go conv.Run(convWg)
+			continue
+		}
+		// In fail-fast mode, files are converted one by one, so the run could be stopped at the first failure.
+		conv.Run(convWg)
+		if conv.Err() != nil {
+			mc.abort(i + 1)
+			break
+		}
 	}
 	convWg.Wait()
+
+	for _, conv := range converters {
+		mc.report.Files = append(mc.report.Files, conv.Report())
+	}
+
+	return nil
+}
+
+// Converts the files and returns the outcome of the run - nil, in case, if all the files were converted,
+// ErrPartialFailure or ErrAllFailed otherwise. Errors, that prevent the run as a whole (e.g. invalid options),
+// are returned as is.
+func (mc *MultipleConverter) Run() error {
+
+	start := time.Now()
+	mc.report = &RunReport{Mode: mc.mode(), Files: []*FileReport{}, Errors: []ReportError{}}
+	err := mc.run()
+	mc.report.finish(err, mc.skipped, time.Since(start))
+
+	if mc.isTree && err == nil {
+		mc.printSummary()
+	}
+
+	if mc.reportFormat == ReportJSON {
+		if reportErr := mc.report.write(mc.reportPath); reportErr != nil {
+			fmt.Fprintln(os.Stderr, reportErr.Error())
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	return mc.report.outcome()
 }
 
+// Returns the report of the last run. Nil, if the converter was not run.
+func (mc *MultipleConverter) Report() *RunReport {
+	return mc.report
+}
+
+// Sets the format of the report and the path, json report is written to (standard output, if empty or '-').
+func (mc *MultipleConverter) SetReport(format ReportFormat, path string) {
+	mc.reportFormat = format
+	mc.reportPath = path
+}
+
+// Sets, whether the run is stopped at the first failed file (fail-fast) or the rest of the files are converted (keep-going).
+func (mc *MultipleConverter) SetFailFast(failFast bool) {
+	mc.failFast = failFast
+}
+
+func (mc *MultipleConverter) SetOptions(opts Options) {
+	mc.opts = opts
+}
 
 func NewMultipleConverter(
 	inputPaths []string, 
@@ -215,6 +540,47 @@ func NewMultipleConverter(
 		isPreview: false,
 		inputPaths: inputPaths,
 		outputPaths: outputPaths, 
+		opts: DefaultOptions(),
+		reportFormat: ReportText,
+	}
+}
+
+// Creates the converter, that decodes the documents of all the Firestore API responses provided
+// (e.g. paginated 'listDocuments' responses) into a single output file.
+func NewMultipleConverterMerge(
+	inputPaths []string, 
+	outputPath string,
+) *MultipleConverter {
+	
+	return &MultipleConverter{
+		isPreview: false,
+		isMerge: true,
+		inputPaths: inputPaths,
+		outputPaths: []string{outputPath}, 
+		opts: DefaultOptions(),
+		reportFormat: ReportText,
+	}
+}
+
+// Creates the converter, that expands the input directories and glob patterns provided into the files and writes
+// their outputs into the output directory, that mirrors the input tree. Include and exclude patterns are applied to the
+// found files - '*.json' files are included by default.
+func NewMultipleConverterTree(
+	inputPaths []string,
+	outputDir string,
+	include []string,
+	exclude []string,
+) *MultipleConverter {
+
+	return &MultipleConverter{
+		isPreview: false,
+		isTree: true,
+		include: include,
+		exclude: exclude,
+		inputPaths: inputPaths,
+		outputPaths: []string{outputDir},
+		opts: DefaultOptions(),
+		reportFormat: ReportText,
 	}
 }
 
@@ -226,5 +592,7 @@ func NewMultipleConverterPreview(
 		isPreview: true,
 		inputPaths: inputPaths,
 		outputPaths: nil, 
+		opts: DefaultOptions(),
+		reportFormat: ReportText,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Defines, in which direction the payloads are converted.
+type DirectionMode string
+
+const (
+	// Direction is detected from the structure of the payload.
+	DirectionAuto DirectionMode = "auto"
+	// Plain JSON -> Firestore.
+	DirectionEncode DirectionMode = "encode"
+	// Firestore -> plain JSON.
+	DirectionDecode DirectionMode = "decode"
+)
+
+// Checks, whether the value is a single Firestore value - {"stringValue": "..."}.
+func isFirestoreValue(value interface{}) bool {
+	valMap, ok := value.(map[string]interface{})
+	if !ok || len(valMap) != 1 {
+		return false
+	}
+	for k := range valMap {
+		return slices.Contains(supportedFields, k)
+	}
+	return false
+}
+
+var (
+	// Top-level keys of the Firestore document.
+	documentKeys = []string{"fields", documentNameKey, documentCreateTimeKey, documentUpdateTimeKey}
+)
+
+// Checks, whether the payload is a Firestore document without fields - the API omits 'fields' of the empty documents,
+// so such document consists of its valid name and, optionally, the rest of the metadata only.
+func isEmptyDocument(payloadMap map[string]interface{}) bool {
+	for k := range payloadMap {
+		if k == "fields" || !slices.Contains(documentKeys, k) {
+			return false
+		}
+	}
+	_, err := handleReferenceValue(payloadMap[documentNameKey])
+	return err == nil
+}
+
+// Detects the direction of the conversion from the structure of the payload. Payload is decoded, in case, if it's
+// a Firestore API response or a document, which 'fields' are the Firestore values, and encoded otherwise.
+// Returns the direction and the reason, it was chosen for.
+func DetectDirection(payload interface{}) (DirectionMode, string) {
+	// Empty object is an empty 'listDocuments' page as well, but it's rather an empty plain JSON document.
+	if payloadMap, ok := payload.(map[string]interface{}); ok && len(payloadMap) == 0 {
+		return DirectionEncode, "it is an empty json object"
+	}
+
+	if isDocumentsResponse(payload) {
+		return DirectionDecode, "it is a 'runQuery', 'batchGet' or 'listDocuments' response"
+	}
+
+	payloadMap, ok := payload.(map[string]interface{})
+	if !ok {
+		return DirectionEncode, "it is not a json object, nor a Firestore API response"
+	}
+
+	// Keys are sorted, so the same reason is reported each time.
+	keys := make([]string, 0, len(payloadMap))
+	for k := range payloadMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !slices.Contains(documentKeys, k) {
+			return DirectionEncode, fmt.Sprintf("its key -> %s is not a part of the Firestore document", k)
+		}
+	}
+
+	rawFields, fieldsFound := payloadMap["fields"]
+	if !fieldsFound {
+		if isEmptyDocument(payloadMap) {
+			return DirectionDecode, "it is a Firestore document without fields"
+		}
+		return DirectionEncode, "it does not contain the 'fields' of the Firestore document"
+	}
+
+	fields, ok := rawFields.(map[string]interface{})
+	if !ok {
+		return DirectionEncode, "its 'fields' key is not a json object"
+	}
+
+	fieldKeys := make([]string, 0, len(fields))
+	for k := range fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+
+	for _, k := range fieldKeys {
+		if !isFirestoreValue(fields[k]) {
+			return DirectionEncode, fmt.Sprintf("its field -> fields/%s is not a Firestore value", k)
+		}
+	}
+
+	return DirectionDecode, "it is a Firestore document, which 'fields' are the Firestore values"
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Document metadata, that is returned by the Firestore API next to the 'fields'.
+const (
+	documentNameKey = "name"
+	documentCreateTimeKey = "createTime"
+	documentUpdateTimeKey = "updateTime"
+)
+
+// Validates the collection path - 'projects/{project_id}/databases/{database_id}/documents/{collection_path}'.
+func handleCollectionPath(collectionPath string) (string, error) {
+	collectionPath = strings.TrimSuffix(collectionPath, "/")
+
+	// Collection path is valid, as long as the path of its document would be a valid reference.
+	if _, err := handleReferenceValue(collectionPath + "/id"); err != nil {
+		return "", newError(ErrInvalidUsage, "", "", fmt.Sprintf(
+			"Collection path -> %s is invalid. It should match the 'projects/{project_id}/databases/{database_id}/documents/{collection_path}' pattern",
+			collectionPath,
+		))
+	}
+
+	return collectionPath, nil
+}
+
+// Checks, whether the document ID could be appended to the collection path - slashes would turn it into
+// a path of the document in a subcollection.
+func isValidDocumentID(id string) bool {
+	return id != "" && !strings.Contains(id, "/")
+}
+
+// Returns the ID of the document, which is the last segment of its name.
+func documentID(name string) string {
+	return name[strings.LastIndex(name, "/") + 1:]
+}
+
+// Surfaces the document metadata in the decoded payload under the keys configured.
+func decodeDocumentMetadata(payload map[string]interface{}, resPayload map[string]interface{}, opts *Options) error {
+	metadataKeys := map[string]string{
+		documentCreateTimeKey: opts.CreateTimeKey,
+		documentUpdateTimeKey: opts.UpdateTimeKey,
+	}
+
+	decodedMetadata := map[string]interface{}{}
+
+	if rawName, found := payload[documentNameKey]; found {
+		name, err := handleReferenceValue(rawName)
+		if err != nil {
+			return wrapError(ErrInvalidValue, documentNameKey, "", err)
+		}
+		if opts.NameKey != "" {
+			decodedMetadata[opts.NameKey] = name
+		}
+		if opts.IDKey != "" {
+			decodedMetadata[opts.IDKey] = documentID(name)
+		}
+	}
+
+	for metadataKey, decodedKey := range metadataKeys {
+		rawTime, found := payload[metadataKey]
+		if !found {
+			continue
+		}
+		timestamp, err := handleTimestampValue(rawTime)
+		if err != nil {
+			return wrapError(ErrInvalidValue, metadataKey, "", err)
+		}
+		if decodedKey != "" {
+			decodedMetadata[decodedKey] = timestamp
+		}
+	}
+
+	for k, v := range decodedMetadata {
+		if _, found := resPayload[k]; found {
+			return newError(ErrInvalidUsage, k, "", "Document metadata can't be put under the key, since the document has a field with the same name")
+		}
+		resPayload[k] = v
+	}
+
+	return nil
+}
+
+// Splits the plain payload into the document fields and the document metadata (name, createTime, updateTime),
+// found under the keys configured.
+func splitDocumentMetadata(payload map[string]interface{}, opts *Options) (map[string]interface{}, map[string]interface{}, error) {
+	metadata := map[string]interface{}{}
+	metadataKeys := map[string]string{
+		opts.CreateTimeKey: documentCreateTimeKey,
+		opts.UpdateTimeKey: documentUpdateTimeKey,
+	}
+
+	if opts.NameKey == "" && opts.IDKey == "" && opts.CreateTimeKey == "" && opts.UpdateTimeKey == "" {
+		return payload, metadata, nil
+	}
+
+	fields := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		fields[k] = v
+	}
+
+	if rawName, found := fields[opts.NameKey]; found && opts.NameKey != "" {
+		name, err := handleReferenceValue(rawName)
+		if err != nil {
+			return nil, nil, wrapError(ErrInvalidValue, opts.NameKey, "", err)
+		}
+		metadata[documentNameKey] = name
+		delete(fields, opts.NameKey)
+	}
+
+	if rawID, found := fields[opts.IDKey]; found && opts.IDKey != "" {
+		id, ok := rawID.(string)
+		if !ok || !isValidDocumentID(id) {
+			return nil, nil, newError(ErrInvalidValue, opts.IDKey, "", "Document ID should be a non-empty string without slashes")
+		}
+		if opts.CollectionPath == "" {
+			return nil, nil, newError(ErrInvalidUsage, "", "", "Collection path is required, in order to build the document name from its ID")
+		}
+		collectionPath, err := handleCollectionPath(opts.CollectionPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := collectionPath + "/" + id
+		if existingName, found := metadata[documentNameKey]; found && existingName != name {
+			return nil, nil, newError(ErrInvalidValue, opts.IDKey, "", fmt.Sprintf("Document name -> %s does not match the document ID -> %s", existingName, id))
+		}
+		metadata[documentNameKey] = name
+		delete(fields, opts.IDKey)
+	}
+
+	for plainKey, metadataKey := range metadataKeys {
+		rawTime, found := fields[plainKey]
+		if !found || plainKey == "" {
+			continue
+		}
+		timestamp, err := handleTimestampValue(rawTime)
+		if err != nil {
+			return nil, nil, wrapError(ErrInvalidValue, plainKey, metadataKey, err)
+		}
+		metadata[metadataKey] = timestamp
+		delete(fields, plainKey)
+	}
+
+	return fields, metadata, nil
+}
@@ -2,9 +2,11 @@ package engine
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -20,13 +22,65 @@ var (
 		"timestampValue",
 		"stringValue",
 		"bytesValue",
-		// "referenceValue", // TODO
-		// "geoPointValue",  // TODO
+		"referenceValue",
+		"geoPointValue",
 		"arrayValue",
 		"mapValue",
 	}
 )
 
+const (
+	// Plain JSON representation of a Firestore reference - {"$reference": "projects/{p}/databases/{d}/documents/..."}.
+	referenceTag = "$reference"
+	// Explicit plain JSON representation of a Firestore geo point - {"$geoPoint": {"latitude": 0, "longitude": 0}}.
+	// Maps, that consist only of 'latitude' and 'longitude' keys within their ranges, are treated as geo points as well.
+	geoPointTag = "$geoPoint"
+	// Plain JSON representation of a Firestore vector embedding - {"$vector": [0.1, 0.2]}.
+	vectorTag = "$vector"
+	// Plain JSON representation of a Firestore double, that can't be expressed by a JSON number - {"$double": "NaN"}.
+	doubleTag = "$double"
+
+	// Type hints, that force the encoding of the value into the specific Firestore type, instead of guessing it.
+	// E.g. {"$string": "abcd="} is encoded into the 'stringValue', even though it looks like a base64 byte value.
+	stringTag = "$string"
+	bytesTag = "$bytes"
+	timestampTag = "$timestamp"
+	integerTag = "$integer"
+	mapTag = "$map"
+
+	// Firestore stores vectors as maps with a special '__type__' marker and the doubles under the 'value' key.
+	vectorTypeKey = "__type__"
+	vectorTypeName = "__vector__"
+	vectorValueKey = "value"
+	maxVectorDimension = 2048
+)
+
+var (
+	valueTags = []string {
+		referenceTag,
+		geoPointTag,
+		vectorTag,
+		doubleTag,
+		stringTag,
+		bytesTag,
+		timestampTag,
+		integerTag,
+		mapTag,
+	}
+
+	typeHintTags = map[string]string {
+		"stringValue": stringTag,
+		"bytesValue": bytesTag,
+		"timestampValue": timestampTag,
+		"integerValue": integerTag,
+		"doubleValue": doubleTag,
+		"mapValue": mapTag,
+	}
+
+	// Doubles are provided as decimal numbers, optionally with an exponent (hex floats, underscores and the like are rejected).
+	decimalNumberRegex = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+)
+
 func generateErrorMessage(path string, typeKey string, reason string) string {
 	return fmt.Sprintf(
 		`Structure under the path -> %s with the type -> %s is invalid! Reason - %s`, 
@@ -34,38 +88,40 @@ func generateErrorMessage(path string, typeKey string, reason string) string {
 	)
 }
 
-func handleMapValue(value interface{}, path string) (map[string]interface{}, error) {
+func handleMapValue(value interface{}, path string, opts *Options) (map[string]interface{}, error) {
 	resMap := make(map[string]interface{}) 
 	mapStructure, ok := value.(map[string]interface{})
 	if !ok {
-		return nil, errors.New("can't cast an object under the 'mapValue' to the 'map' type")
+		return nil, newError(ErrInvalidStructure, path, "mapValue", "can't cast an object under the 'mapValue' to the 'map' type")
 	}
 	
-	fieldsFound := false
+	// Firestore omits the 'fields' of an empty map ({"mapValue": {}}), so the missing attribute stands for the empty one.
 	var fieldsMap map[string]interface{}
 	for k, v := range mapStructure {
 		if k == "fields" {
-			fieldsFound = true
 			fieldsMap, ok = v.(map[string]interface{})
 			if !ok {
-				return nil, fmt.Errorf("can't cast the 'fields' attribute under the 'mapValue' object to a map type. Path - %s", path)
+				return nil, newError(ErrInvalidStructure, path, "mapValue", "can't cast the 'fields' attribute under the 'mapValue' object to a map type")
 			}
 			path += "/fields"
 			break
 		}
 	}
 
-	if !fieldsFound {
-		return nil, fmt.Errorf("'mapValue' object does not contain an obligatory field - 'fields'. Path - %s", path)
-	}
-	
 	for k, v := range fieldsMap {
 		fieldValMap, ok := v.(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("can't cast the value under path - %s to a map", path+fmt.Sprintf("/%s", k))
+			castErr := newError(ErrInvalidStructure, path + fmt.Sprintf("/%s", k), "", "can't cast the value to a map")
+			if opts.collectError(castErr) {
+				continue
+			}
+			return nil, castErr
 		}
-		mapVal, err := handleFirestoreType(fieldValMap, path + fmt.Sprintf("/%s", k))
+		mapVal, err := handleFirestoreType(fieldValMap, path + fmt.Sprintf("/%s", k), opts)
 		if err != nil {
+			if opts.collectError(err) {
+				continue
+			}
 			return nil, err
 		}
 		resMap[k] = mapVal
@@ -74,45 +130,54 @@ func handleMapValue(value interface{}, path string) (map[string]interface{}, err
 	return resMap, nil
 }
 
-func handleGoMap(payloadVal interface{}, path string) (map[string]map[string]interface{} , error) {
-	firestoreMapObject := map[string]map[string]interface{}{"mapValue": {"fields": map[string]interface{}{}}}
+func handleGoMap(payloadVal interface{}, path string, opts *Options) (map[string]interface{} , error) {
+	firestoreMapFields := map[string]interface{}{"fields": map[string]interface{}{}}
+	firestoreMapObject := map[string]interface{}{"mapValue": firestoreMapFields}
 
 	mapVal, ok := payloadVal.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("can't cast the value under the following path - %s to a map.", path)
+		return nil, newError(ErrInvalidStructure, path, "", "can't cast the value to a map.")
 	}
 
+	// Encoded values are put into a new map, so the payload provided is not modified.
+	encodedMap := make(map[string]interface{}, len(mapVal))
 	for k, v := range mapVal {
 		if slices.Contains(supportedFields, k) {
-			return nil, fmt.Errorf("Object under the path -> %s, contains the key -> %s, which is the Firestore type", path, k)
+			keyErr := newError(ErrInvalidStructure, path, "", fmt.Sprintf("Object contains the key -> %s, which is the Firestore type", k))
+			if opts.collectError(keyErr) {
+				continue
+			}
+			return nil, keyErr
 		}
-		processedVal, err := handleGoType(v, path + "/" + k)
+		processedVal, err := handleGoType(v, path + "/" + k, opts)
 		if err != nil {
+			if opts.collectError(err) {
+				continue
+			}
 			return  nil, err
 		}
-		mapVal[k] = processedVal
+		encodedMap[k] = processedVal
 	}
 	
-	firestoreMapObject["mapValue"]["fields"] = mapVal
+	firestoreMapFields["fields"] = encodedMap
 	return firestoreMapObject, nil
 }
 
-func handleArrayValue(value interface{}, path string) ([]interface{}, error) {
+func handleArrayValue(value interface{}, path string, opts *Options) ([]interface{}, error) {
 	resArr := []interface{} {}
 	arrayMap, ok := value.(map[string]interface{})
 	if !ok {
-		return nil, errors.New("can't cast the value provided for the arrayValue to the map")
+		return nil, newError(ErrInvalidStructure, path, "arrayValue", "can't cast the value provided for the arrayValue to the map")
 	}
 
-	valuesFound := false
+	// Firestore omits the 'values' of an empty array ({"arrayValue": {}}), so the missing attribute stands for the empty one.
 	var valuesArray []interface{}
 	for k, v := range arrayMap {
 		if k == "values" {
-			valuesFound = true
 			valuesArray, ok = v.([]interface{})
 			if !ok {
-				return nil, errors.New(
-					`can't cast the 'values' attribute under the 'arrayValue' object to an array`,
+				return nil, newError(
+					ErrInvalidStructure, path, "arrayValue", `can't cast the 'values' attribute under the 'arrayValue' object to an array`,
 				)
 			}
 			path += "/values"
@@ -120,17 +185,20 @@ func handleArrayValue(value interface{}, path string) ([]interface{}, error) {
 		}
 	}
 
-	if !valuesFound {
-		return nil, errors.New("'arrayValue' object does not contain an obligatory field - 'values'")
-	}
-
 	for i, v := range valuesArray {
 		arrValMap, ok := v.(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("can't cast the array val under path - %s to a map", path+fmt.Sprintf("[%d]", i))
+			castErr := newError(ErrInvalidStructure, path + fmt.Sprintf("[%d]", i), "", "can't cast the array val to a map")
+			if opts.collectError(castErr) {
+				continue
+			}
+			return nil, castErr
 		}
-		arrVal, err := handleFirestoreType(arrValMap, path + fmt.Sprintf("[%d]", i))
+		arrVal, err := handleFirestoreType(arrValMap, path + fmt.Sprintf("[%d]", i), opts)
 		if err != nil {
+			if opts.collectError(err) {
+				continue
+			}
 			return nil, err
 		}
 		resArr = append(resArr, arrVal)
@@ -139,24 +207,29 @@ func handleArrayValue(value interface{}, path string) ([]interface{}, error) {
 	return resArr, nil
 }
 
-func handleGoArray(payloadVal interface{}, path string) (interface{}, error) {
+func handleGoArray(payloadVal interface{}, path string, opts *Options) (interface{}, error) {
 
-	firestoreArrayObject := map[string]map[string]interface{} {"arrayValue":{"values": []interface{}{}}}
+	firestoreArrayValues := map[string]interface{}{"values": []interface{}{}}
+	firestoreArrayObject := map[string]interface{} {"arrayValue": firestoreArrayValues}
 
 	payloadArr, ok := payloadVal.([]interface{})
 	if !ok {
-		return nil, fmt.Errorf("Can't cast a value under the following path in the payload -> %s to an array.", path)
+		return nil, newError(ErrInvalidStructure, path, "", "Can't cast a value to an array.")
 	}
 
+	encodedArr := make([]interface{}, len(payloadArr))
 	for i, elem := range payloadArr {
-		processedElem, err := handleGoType(elem, path +  fmt.Sprintf("[%d]", i))
+		processedElem, err := handleGoType(elem, path +  fmt.Sprintf("[%d]", i), opts)
 		if err != nil {
+			if opts.collectError(err) {
+				continue
+			}
 			return nil, err
 		}
-		payloadArr[i] = processedElem
+		encodedArr[i] = processedElem
 	}
 
-	firestoreArrayObject["arrayValue"]["values"] = payloadArr
+	firestoreArrayValues["values"] = encodedArr
 	return firestoreArrayObject, nil	
 }
 
@@ -193,6 +266,320 @@ func handleByteValue(value interface{}) (string, error) {
 	return strValue, nil
 }
 
+// Validates the byte value, which type is known upfront, so the padding is not required to distinguish it from a string.
+func handleExplicitByteValue(value interface{}) (string, error) {
+	strValue, ok := value.(string)
+	if !ok {
+		return "", errors.New("byte value is not in a string format.")
+	}
+
+	if _, err := base64.StdEncoding.Strict().DecodeString(strValue); err != nil {
+		return "", err
+	}
+
+	return strValue, nil
+}
+
+func handleReferenceValue(value interface{}) (string, error) {
+	strRef, ok := value.(string)
+	if !ok {
+		return "", errors.New("reference value is not in a string format.")
+	}
+
+	segments := strings.Split(strRef, "/")
+	if len(segments) < 5 || segments[0] != "projects" || segments[2] != "databases" || segments[4] != "documents" {
+		return "", fmt.Errorf(
+			"The following reference -> %s does not match the 'projects/{project_id}/databases/{database_id}/documents/{document_path}' pattern",
+			strRef,
+		)
+	}
+
+	for i, segment := range segments {
+		if segment == "" {
+			return "", fmt.Errorf("The following reference -> %s contains an empty segment at the position %d", strRef, i)
+		}
+	}
+
+	// Document path is a sequence of collection/document ID pairs, so the amount of its segments should be even.
+	docSegments := segments[5:]
+	if len(docSegments) == 0 || len(docSegments) % 2 != 0 {
+		return "", fmt.Errorf(
+			"The following reference -> %s should point to a document, so its document path should contain an even amount of segments",
+			strRef,
+		)
+	}
+
+	return strRef, nil
+}
+
+func handleGeoPointValue(value interface{}) (map[string]interface{}, error) {
+	geoMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("geo point value is not in an object format.")
+	}
+
+	for k := range geoMap {
+		if k != "latitude" && k != "longitude" {
+			return nil, fmt.Errorf("geo point value contains an unexpected key -> %s", k)
+		}
+	}
+
+	// Zero coordinates might be omitted by the Firestore API, so absent keys are treated as zeros.
+	coordinates := map[string]float64{"latitude": 0, "longitude": 0}
+	for k := range coordinates {
+		rawVal, found := geoMap[k]
+		if !found {
+			continue
+		}
+		floatVal, ok := toFloat64(rawVal)
+		if !ok {
+			return nil, fmt.Errorf("'%s' of the geo point value is not a number", k)
+		}
+		coordinates[k] = floatVal
+	}
+
+	if lat := coordinates["latitude"]; !(lat >= -90 && lat <= 90) {
+		return nil, fmt.Errorf("latitude -> %v is out of the [-90, 90] range", lat)
+	}
+
+	if lng := coordinates["longitude"]; !(lng >= -180 && lng <= 180) {
+		return nil, fmt.Errorf("longitude -> %v is out of the [-180, 180] range", lng)
+	}
+
+	return map[string]interface{}{"latitude": coordinates["latitude"], "longitude": coordinates["longitude"]}, nil
+}
+
+// Checks, whether the map provided is a valid geo point. Maps with the coordinates out of their ranges
+// (e.g. {"latitude": 120, "longitude": 5}) are the regular ones, only the '$geoPoint' hint is validated strictly.
+func isGeoPointShape(mapVal map[string]interface{}) bool {
+	if len(mapVal) != 2 {
+		return false
+	}
+	_, err := handleGeoPointValue(mapVal)
+	return err == nil
+}
+
+// Handles maps, that are representing a single Firestore value in the plain JSON (e.g. {"$reference": "..."}).
+// Returns false, in case, if the map provided is a regular one.
+func handleGoTaggedMap(mapVal map[string]interface{}, path string, opts *Options) (interface{}, bool, error) {
+	if isGeoPointShape(mapVal) {
+		geoPoint, err := handleGeoPointValue(mapVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, path, "geoPointValue", err)
+		}
+		return handleGoSingularType(geoPoint, "geoPointValue"), true, nil
+	}
+
+	if len(mapVal) != 1 {
+		return nil, false, nil
+	}
+
+	var tag string
+	var tagVal interface{}
+	for k, v := range mapVal {
+		tag = k
+		tagVal = v
+	}
+
+	tagPath := path + "/" + tag
+	switch tag {
+	case geoPointTag:
+		geoPoint, err := handleGeoPointValue(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "geoPointValue", err)
+		}
+		return handleGoSingularType(geoPoint, "geoPointValue"), true, nil
+	case vectorTag:
+		vector, err := handleGoVector(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "vectorValue", err)
+		}
+		return vector, true, nil
+	case doubleTag:
+		floatNum, err := handleFloatType(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "doubleValue", err)
+		}
+		return handleGoSingularType(floatNum, "doubleValue"), true, nil
+	case integerTag:
+		intNum, err := handleGoInteger(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "integerValue", err)
+		}
+		return handleGoSingularType(intNum, "integerValue"), true, nil
+	case referenceTag:
+		ref, err := handleReferenceValue(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "referenceValue", err)
+		}
+		return handleGoSingularType(ref, "referenceValue"), true, nil
+	case stringTag:
+		strVal, err := handleSingularType[string](tagVal)
+		if err != nil {
+			return nil, true, newError(ErrInvalidValue, tagPath, "stringValue", "Value is not a string type.")
+		}
+		return handleGoSingularType(strVal, "stringValue"), true, nil
+	case bytesTag:
+		bytesVal, err := handleExplicitByteValue(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "bytesValue", err)
+		}
+		return handleGoSingularType(bytesVal, "bytesValue"), true, nil
+	case timestampTag:
+		timestampVal, err := handleTimestampValue(tagVal)
+		if err != nil {
+			return nil, true, wrapError(ErrInvalidValue, tagPath, "timestampValue", err)
+		}
+		return handleGoSingularType(timestampVal, "timestampValue"), true, nil
+	case mapTag:
+		// Content of the hinted map is encoded as a regular map, even if it looks like a tagged value or a geo point.
+		firestoreMapObject, err := handleGoMap(tagVal, tagPath, opts)
+		if err != nil {
+			return nil, true, err
+		}
+		return firestoreMapObject, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// Checks, whether the map provided would be encoded as something, other than a regular 'mapValue'.
+func isTaggedShape(mapVal map[string]interface{}) bool {
+	if isGeoPointShape(mapVal) {
+		return true
+	}
+	if len(mapVal) != 1 {
+		return false
+	}
+	for k := range mapVal {
+		return slices.Contains(valueTags, k)
+	}
+	return false
+}
+
+// Returns the Firestore type, a plain string would be encoded into, in case, if no type hint is provided.
+func detectStringType(strVal string) string {
+	if _, err := handleByteValue(strVal); err == nil {
+		return "bytesValue"
+	}
+	if _, err := handleTimestampValue(strVal); err == nil {
+		return "timestampValue"
+	}
+	return "stringValue"
+}
+
+// Wraps the decoded string value into the type hint, in case, if the encoder would guess a different type for it
+// or take it for the transform sentinel.
+func handleStringTypeHint(strVal string, typeKey string, opts *Options) interface{} {
+	if !opts.EmitTypeHints || (detectStringType(strVal) == typeKey && strVal != serverTimestampSentinel) {
+		return strVal
+	}
+	return map[string]interface{}{typeHintTags[typeKey]: strVal}
+}
+
+// Checks, whether the 'mapValue' object provided is a Firestore vector - {"fields": {"__type__": {"stringValue": "__vector__"}, "value": {...}}}.
+func isFirestoreVector(value interface{}) bool {
+	mapStructure, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	fieldsMap, ok := mapStructure["fields"].(map[string]interface{})
+	if !ok || len(fieldsMap) != 2 {
+		return false
+	}
+
+	typeMap, ok := fieldsMap[vectorTypeKey].(map[string]interface{})
+	if !ok || len(typeMap) != 1 || typeMap["stringValue"] != vectorTypeName {
+		return false
+	}
+
+	_, valueFound := fieldsMap[vectorValueKey]
+	return valueFound
+}
+
+func validateVectorDimension(dimension int) error {
+	if dimension == 0 || dimension > maxVectorDimension {
+		return fmt.Errorf("vector dimension -> %d should be in the [1, %d] range", dimension, maxVectorDimension)
+	}
+	return nil
+}
+
+func handleVectorValue(value interface{}, opts *Options) (interface{}, error) {
+	fieldsMap := value.(map[string]interface{})["fields"].(map[string]interface{})
+
+	valueMap, ok := fieldsMap[vectorValueKey].(map[string]interface{})
+	if !ok || len(valueMap) != 1 {
+		return nil, errors.New("vector 'value' should be an object with the single 'arrayValue' key")
+	}
+
+	arrayMap, ok := valueMap["arrayValue"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("vector 'value' should be an 'arrayValue' object")
+	}
+
+	valuesArray, ok := arrayMap["values"].([]interface{})
+	if !ok {
+		return nil, errors.New("'arrayValue' of the vector does not contain a 'values' array")
+	}
+
+	if err := validateVectorDimension(len(valuesArray)); err != nil {
+		return nil, err
+	}
+
+	resArr := make([]interface{}, 0, len(valuesArray))
+	for i, v := range valuesArray {
+		elemMap, ok := v.(map[string]interface{})
+		if !ok || len(elemMap) != 1 {
+			return nil, fmt.Errorf("vector element under the index %d should be an object with the single 'doubleValue' key", i)
+		}
+		rawNum, ok := elemMap["doubleValue"]
+		if !ok {
+			return nil, fmt.Errorf("vector element under the index %d is not a 'doubleValue'", i)
+		}
+		floatNum, err := handleFloatType(rawNum)
+		if err != nil {
+			return nil, fmt.Errorf("vector element under the index %d is invalid - %w", i, err)
+		}
+		resArr = append(resArr, floatNum)
+	}
+
+	// Plain array would be encoded back into the 'arrayValue', so vectors are always tagged, when type hints are emitted.
+	if opts.TagVectors || opts.EmitTypeHints {
+		return map[string]interface{}{vectorTag: resArr}, nil
+	}
+	return resArr, nil
+}
+
+func handleGoVector(value interface{}) (map[string]interface{}, error) {
+	payloadArr, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("vector value is not an array")
+	}
+
+	if err := validateVectorDimension(len(payloadArr)); err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(payloadArr))
+	for i, elem := range payloadArr {
+		floatNum, ok := toFloat64(elem)
+		if !ok {
+			return nil, fmt.Errorf("vector element under the index %d is not a number", i)
+		}
+		values = append(values, handleGoSingularType(floatNum, "doubleValue"))
+	}
+
+	return map[string]interface{}{
+		"mapValue": map[string]interface{}{
+			"fields": map[string]interface{}{
+				vectorTypeKey: handleGoSingularType(vectorTypeName, "stringValue"),
+				vectorValueKey: map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}},
+			},
+		},
+	}, nil
+}
+
 func handleSingularType[T any](value interface{}) (T, error) {
 	covertedVal, ok := value.(T)
 	if !ok {
@@ -204,17 +591,138 @@ func handleSingularType[T any](value interface{}) (T, error) {
 func handleGoSingularType(val interface{}, firestoreType string) map[string]interface{} {
 	firestoreObject := make(map[string]interface{})
 	if firestoreType == "integerValue" || firestoreType == "doubleValue" {
-		firestoreObject = map[string]interface{}{firestoreType:strconv.FormatFloat(val.(float64), 'f', -1, 64)}
+		firestoreObject = map[string]interface{}{firestoreType:formatGoNumber(val)}
 	} else {
 		firestoreObject = map[string]interface{}{firestoreType:val}
 	}
 	return firestoreObject
 }
 
-func handleIntFloatType(value interface{}) (float64, error) {
-	strNum, ok := value.(string)
-	if !ok {
-		return 0, errors.New("Integer/Double value is supposed to be provided in a form of a string")
+func formatGoNumber(val interface{}) string {
+	switch num := val.(type) {
+	case int64:
+		return strconv.FormatInt(num, 10)
+	case int:
+		return strconv.Itoa(num)
+	default:
+		if specialDouble, isSpecial := formatSpecialDouble(val.(float64)); isSpecial {
+			return specialDouble
+		}
+		return strconv.FormatFloat(val.(float64), 'f', -1, 64)
+	}
+}
+
+// Returns the Firestore API representation of the NaN, Infinity and -Infinity doubles.
+func formatSpecialDouble(val float64) (string, bool) {
+	switch {
+	case math.IsNaN(val):
+		return "NaN", true
+	case math.IsInf(val, 1):
+		return "Infinity", true
+	case math.IsInf(val, -1):
+		return "-Infinity", true
+	}
+	return "", false
+}
+
+func handleSpecialDouble(specialDouble string, opts *Options) interface{} {
+	switch opts.SpecialDoubles {
+	case SpecialDoublesString:
+		return specialDouble
+	case SpecialDoublesNull:
+		return nil
+	}
+	return map[string]interface{}{doubleTag: specialDouble}
+}
+
+// Keeps a fraction part in the textual representation of the double (e.g. 3 -> 3.0),
+// so it is not mistaken for an integer, when the payload is encoded back.
+func formatDoubleNumber(val float64) json.Number {
+	strNum := strconv.FormatFloat(val, 'f', -1, 64)
+	if !strings.Contains(strNum, ".") {
+		strNum += ".0"
+	}
+	return json.Number(strNum)
+}
+
+// Converts numeric values, that might appear in the payload, to float64.
+func toFloat64(value interface{}) (float64, bool) {
+	switch num := value.(type) {
+	case float64:
+		return num, true
+	case int64:
+		return float64(num), true
+	case int:
+		return float64(num), true
+	case json.Number:
+		floatNum, err := num.Float64()
+		return floatNum, err == nil
+	}
+	return 0, false
+}
+
+func parseInt64(strNum string) (int64, error) {
+	intNum, err := strconv.ParseInt(strNum, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("integer -> %s is out of the 64-bit signed integer range", strNum)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid integer", strNum)
+	}
+	return intNum, nil
+}
+
+// Converts the value of the '$integer' type hint, which might be provided either as a number or as a string.
+func handleGoInteger(value interface{}) (int64, error) {
+	switch num := value.(type) {
+	case float64:
+		if math.Mod(num, 1) != 0 || num < math.MinInt64 || num >= math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not a valid 64-bit integer", num)
+		}
+		return int64(num), nil
+	case int64:
+		return num, nil
+	case int:
+		return int64(num), nil
+	}
+	return handleIntType(value)
+}
+
+func handleIntType(value interface{}) (int64, error) {
+	switch num := value.(type) {
+	case string:
+		return parseInt64(num)
+	case json.Number:
+		return parseInt64(num.String())
+	}
+	return 0, errors.New("Integer value is supposed to be provided in a form of a string")
+}
+
+func handleFloatType(value interface{}) (float64, error) {
+	var strNum string
+	switch num := value.(type) {
+	case float64:
+		return num, nil
+	case json.Number:
+		strNum = num.String()
+	case string:
+		strNum = num
+	default:
+		return 0, errors.New("Double value is supposed to be provided in a form of a string or a number")
+	}
+
+	// Only the spellings, produced by the Firestore API, are accepted for the special doubles (e.g. not 'inf' or 'nan').
+	switch strNum {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+
+	if !decimalNumberRegex.MatchString(strNum) {
+		return 0, fmt.Errorf("%s is not a valid decimal number, 'NaN', 'Infinity' or '-Infinity'", strNum)
 	}
 
 	floatNum, err := strconv.ParseFloat(strNum, 64)
@@ -225,15 +733,32 @@ func handleIntFloatType(value interface{}) (float64, error) {
 	return floatNum, nil
 }
 
+// Numbers are read from the input as json.Number, so integers could be kept as int64 without the precision loss.
+func handleGoNumber(num json.Number) (interface{}, error) {
+	strNum := num.String()
+	if !strings.ContainsAny(strNum, ".eE") {
+		intNum, err := strconv.ParseInt(strNum, 10, 64)
+		if err == nil {
+			return intNum, nil
+		}
+		if !errors.Is(err, strconv.ErrRange) {
+			return nil, fmt.Errorf("%s is not a valid integer", strNum)
+		}
+		// Whole numbers outside of the 64-bit integer range are stored as doubles, regardless of their spelling
+		// (e.g. 1000000000000000000000000000000 and 1e30).
+	}
+	return handleFloatType(num)
+}
+
 
-func handleFirestoreType(childPayload map[string]interface{}, path string) (interface{}, error) {
+func handleFirestoreType(childPayload map[string]interface{}, path string, opts *Options) (interface{}, error) {
 
 	if len(childPayload) > 1 {
-		return nil, fmt.Errorf("Structure under the path -> %s is invalid! It contains more than one type key.", path)
+		return nil, newError(ErrInvalidStructure, path, "", "It contains more than one type key.")
 	}
 	
 	if len(childPayload) == 0 {
-		return nil, fmt.Errorf("Structure under the path -> %s is invalid! It contains no keys.", path)
+		return nil, newError(ErrInvalidStructure, path, "", "It contains no keys.")
 	}
 
 	var typeKey string
@@ -244,14 +769,14 @@ func handleFirestoreType(childPayload map[string]interface{}, path string) (inte
 	}
 
 	if !slices.Contains(supportedFields, typeKey) {
-		return nil, fmt.Errorf("Structure under the path -> %s is invalid! It contains an invalid type -> %s", path, typeKey)
+		return nil, newError(ErrUnsupportedType, path, "", fmt.Sprintf("It contains an invalid type -> %s", typeKey))
 	}
 
 	switch typeKey {
 	case "nullValue":
 		path += "/nullValue"
 		if typeVal != nil {
-			return nil, errors.New(generateErrorMessage(path, typeKey, "Value is not null"))
+			return nil, newError(ErrInvalidValue, path, typeKey, "Value is not null")
 		}
 		return nil, nil
 	case "booleanValue":
@@ -259,100 +784,148 @@ func handleFirestoreType(childPayload map[string]interface{}, path string) (inte
 		if val, err := handleSingularType[bool](typeVal); err == nil {
 			return val, nil
 		}
-		return nil, errors.New(generateErrorMessage(path, typeKey, "Value is not a boolean type."))
+		return nil, newError(ErrInvalidValue, path, typeKey, "Value is not a boolean type.")
 	case "integerValue":
 		path += "/integerValue"
-		val, err := handleIntFloatType(typeVal)
+		val, err := handleIntType(typeVal)
 		if err == nil {
 			return val, nil
 		}
-		return nil, errors.New(generateErrorMessage(path, typeKey, err.Error()))
+		return nil, wrapError(ErrInvalidValue, path, typeKey, err)
 	case "doubleValue":
 		path += "/doubleValue"
-		val, err := handleIntFloatType(typeVal)
+		val, err := handleFloatType(typeVal)
 		if err != nil {
-			return nil, errors.New(generateErrorMessage(path, typeKey, err.Error()))
+			return nil, wrapError(ErrInvalidValue, path, typeKey, err)
+		}
+		// Plain JSON can't carry NaN/Infinity numbers, so they are represented in the way configured.
+		if specialDouble, isSpecial := formatSpecialDouble(val); isSpecial {
+			return handleSpecialDouble(specialDouble, opts), nil
+		}
+		if opts.PreserveNumberTypes {
+			return formatDoubleNumber(val), nil
+		}
+		// Whole doubles would be encoded back as integers without the hint.
+		if opts.EmitTypeHints && math.Mod(val, 1) == 0 {
+			return map[string]interface{}{doubleTag: val}, nil
 		}
 		return val, nil
 	case "stringValue":
 		path += "/stringValue"
 		if val, err := handleSingularType[string](typeVal); err == nil {
-			return val, nil
+			return handleStringTypeHint(val, typeKey, opts), nil
 		}
-		return nil, errors.New(generateErrorMessage(path, typeKey, "Value is not a string type."))
+		return nil, newError(ErrInvalidValue, path, typeKey, "Value is not a string type.")
 	case "bytesValue":
 		path += "/bytesValue"
-		val, err := handleByteValue(typeVal)
+		val, err := handleExplicitByteValue(typeVal)
 		if err == nil {
-			return val, nil
+			return handleStringTypeHint(val, typeKey, opts), nil
 		}
-		return nil, errors.New(generateErrorMessage(path, typeKey, err.Error()))
+		return nil, wrapError(ErrInvalidValue, path, typeKey, err)
 	case "timestampValue":
 		path += "/timestampValue"
 		val, err := handleTimestampValue(typeVal)
+		if err == nil {
+			return handleStringTypeHint(val, typeKey, opts), nil
+		}
+		return nil, wrapError(ErrInvalidValue, path, typeKey, err)
+	case "referenceValue":
+		path += "/referenceValue"
+		val, err := handleReferenceValue(typeVal)
+		if err == nil {
+			return map[string]interface{}{referenceTag: val}, nil
+		}
+		return nil, wrapError(ErrInvalidValue, path, typeKey, err)
+	case "geoPointValue":
+		path += "/geoPointValue"
+		val, err := handleGeoPointValue(typeVal)
 		if err == nil {
 			return val, nil
 		}
-		return nil, errors.New(generateErrorMessage(path, typeKey, err.Error()))
+		return nil, wrapError(ErrInvalidValue, path, typeKey, err)
 	case "arrayValue":
 		// Check error handling
 		path += "/arrayValue"
-		val, err := handleArrayValue(typeVal, path)
+		val, err := handleArrayValue(typeVal, path, opts)
 		if err == nil {
 			return val, nil
 		}
-		// return nil, errors.New(generateErrorMessage(path, typeKey, err.Error()))
+		// return nil, wrapError(ErrInvalidValue, path, typeKey, err)
 		return nil, err
 	case "mapValue":
 		// Check error handling
 		path += "/mapValue"
-		val, err := handleMapValue(typeVal, path)
+		if isFirestoreVector(typeVal) {
+			val, err := handleVectorValue(typeVal, opts)
+			if err != nil {
+				return nil, wrapError(ErrInvalidValue, path, typeKey, err)
+			}
+			return val, nil
+		}
+		val, err := handleMapValue(typeVal, path, opts)
 		if err == nil {
+			if opts.EmitTypeHints && isTaggedShape(val) {
+				return map[string]interface{}{mapTag: val}, nil
+			}
+			// Maps with the transform sentinel keys would be taken for the transforms by the write bodies.
+			if _, isTransform := findTransformTag(val); opts.EmitTypeHints && isTransform {
+				return map[string]interface{}{mapTag: val}, nil
+			}
 			return val, nil
 		}
-		// return nil, errors.New(generateErrorMessage(path, typeKey, err.Error()))
+		// return nil, wrapError(ErrInvalidValue, path, typeKey, err)
 		return nil, err
 	}
 
-	return nil, fmt.Errorf("Unsupported firestore field type - %s. Path -> %s", typeKey, path)
+	return nil, newError(ErrUnsupportedType, path, typeKey, fmt.Sprintf("Unsupported firestore field type - %s.", typeKey))
 }
 
-func handleGoType(payloadVal interface{}, path string) (interface{}, error) {
+func handleGoType(payloadVal interface{}, path string, opts *Options) (interface{}, error) {
 	var generalErr error = nil
 	switch t := payloadVal.(type) {
 		case string:
-			// Check if byte
-			if _, err := handleByteValue(payloadVal); err == nil {
-				return handleGoSingularType(payloadVal, "bytesValue"), nil
-			}
-			// Check if timestamp
-			if _, err := handleTimestampValue(payloadVal); err == nil {
-				return handleGoSingularType(payloadVal, "timestampValue"), nil
-			}
-			return handleGoSingularType(payloadVal, "stringValue"), nil
+			// Byte and timestamp values are guessed from the string shape, unless the type hint is provided.
+			return handleGoSingularType(payloadVal, detectStringType(t)), nil
 		case nil:
 			return handleGoSingularType(payloadVal, "nullValue"), nil
 		case bool:
 			return handleGoSingularType(payloadVal, "booleanValue"), nil
+		case json.Number:
+			numVal, err := handleGoNumber(t)
+			if err != nil {
+				return nil, wrapError(ErrInvalidValue, path, "number", err)
+			}
+			// Number, written with a fraction or an exponent, is a double, even if its value is a whole one (e.g. 3.0).
+			if floatNum, isFloat := numVal.(float64); isFloat && opts.PreserveNumberTypes {
+				return handleGoSingularType(floatNum, "doubleValue"), nil
+			}
+			return handleGoType(numVal, path, opts)
+		case int64, int:
+			return handleGoSingularType(payloadVal, "integerValue"), nil
 		case float64:
-			if math.Mod(payloadVal.(float64), 1) == 0 {
+			// Whole numbers outside of the 64-bit integer range (e.g. 1e30) can only be stored as doubles.
+			if math.Mod(t, 1) == 0 && t >= math.MinInt64 && t < math.MaxInt64 {
 				return handleGoSingularType(payloadVal, "integerValue"), nil
 			}
 			return handleGoSingularType(payloadVal, "doubleValue"), nil
 		case []interface{}:
-			firestoreArrayObject, err := handleGoArray(payloadVal, path)
+			firestoreArrayObject, err := handleGoArray(payloadVal, path, opts)
 			if err != nil {
 				return nil, err
 			}
 			return firestoreArrayObject, nil 
 		case map[string]interface{}:
-			firestoreMapObject, err := handleGoMap(payloadVal, path)
+			if taggedVal, isTagged, err := handleGoTaggedMap(t, path, opts); isTagged {
+				return taggedVal, err
+			}
+			firestoreMapObject, err := handleGoMap(payloadVal, path, opts)
 			if err != nil {
 				return nil, err
 			}
 			return firestoreMapObject, nil
 		default:
-			generalErr = fmt.Errorf("the following type - %s, which was found under the path - %s is not supported!", t, path)
+			generalErr = newError(ErrUnsupportedType, path, "", fmt.Sprintf("the following type - %T is not supported!", t))
 	}
 
 	return nil, generalErr
@@ -360,7 +933,12 @@ func handleGoType(payloadVal interface{}, path string) (interface{}, error) {
 
 
 func DecodeFromFirestore(payload map[string]interface{}) (map[string]interface{}, error) {
+	return DecodeFromFirestoreWithOptions(payload, DefaultOptions())
+}
+
+func DecodeFromFirestoreWithOptions(payload map[string]interface{}, opts Options) (map[string]interface{}, error) {
 	resPayload := make(map[string]interface{})
+	opts.startCollecting()
 
 	fieldsFound := false
 	for k := range payload {
@@ -370,40 +948,99 @@ func DecodeFromFirestore(payload map[string]interface{}) (map[string]interface{}
 		}
 	}
 
-	if !fieldsFound {
-		return nil, errors.New("'fields' root parameter is required for the appropiate Firestore API payload.")
+	// Firestore API omits 'fields' of the empty documents, so the document name is enough to recognize the payload,
+	// as long as the rest of its keys are the document metadata.
+	if !fieldsFound && !isEmptyDocument(payload) {
+		return nil, newError(ErrInvalidStructure, "", "", "'fields' root parameter is required for the appropiate Firestore API payload.")
 	}
 
-	payloadFields, ok := payload["fields"].(map[string]interface{})
-	if !ok {
-		return nil, errors.New("data under the 'field' key of the payload can't be converted to the go map.")
+	payloadFields := map[string]interface{}{}
+	if fieldsFound {
+		var ok bool
+		payloadFields, ok = payload["fields"].(map[string]interface{})
+		if !ok {
+			return nil, newError(ErrInvalidStructure, "fields", "", "data under the 'field' key of the payload can't be converted to the go map.")
+		}
 	}
 
 	for k, v := range payloadFields {
 		valMap, ok := v.(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("Can't cast an object under the following key - %s to a map", k)
+			castErr := newError(ErrInvalidStructure, k, "", "Can't cast an object to a map")
+			if opts.collectError(castErr) {
+				continue
+			}
+			return nil, opts.conversionError(castErr)
 		}
-		val, err := handleFirestoreType(valMap, k)
+		val, err := handleFirestoreType(valMap, k, &opts)
 		if err != nil {
-			return nil, err
+			if opts.collectError(err) {
+				continue
+			}
+			return nil, opts.conversionError(err)
 		}
 		resPayload[k] = val
 	}
+
+	if err := opts.conversionError(nil); err != nil {
+		return nil, err
+	}
+
+	if err := decodeDocumentMetadata(payload, resPayload, &opts); err != nil {
+		return nil, err
+	}
+
 	return resPayload, nil
 }
 
 func EncodeToFirestore(payload map[string]interface{}) (map[string]interface{}, error) {
+	return EncodeToFirestoreWithOptions(payload, DefaultOptions())
+}
+
+// Encodes the plain payload into the Firestore document. Document name and timestamps are taken from the keys,
+// configured in the options, while the rest of the keys become the document fields.
+func EncodeToFirestoreWithOptions(payload map[string]interface{}, opts Options) (map[string]interface{}, error) {
+	if containsTransforms(payload) {
+		return nil, newError(
+			ErrInvalidTransform, "", "",
+			"Payload contains field transforms (e.g. '$serverTimestamp'), which could only be written with the 'commit' or 'batchWrite' request bodies.",
+		)
+	}
+
+	fields, metadata, err := splitDocumentMetadata(payload, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// In case, if errors are collected, constraint violations are reported alongside the encoding errors.
+	opts.startCollecting()
+	if err := validateConstraints(fields); err != nil && !opts.collectError(err) {
+		return nil, opts.conversionError(err)
+	}
+
 	encodedPayload := make(map[string]interface{})
-	resPayload := make(map[string]interface{})
+	resPayload := metadata
 
-	for k, v := range payload {
-		encodedVal, err := handleGoType(v, k)
-		if err != nil {
-			return nil, err
+	for k, v := range fields {
+		var encodedVal interface{}
+		var encodeErr error
+		if opts.Schema != nil {
+			encodedVal, encodeErr = handleSchemaType(v, []string{k}, k, opts.Schema, &opts)
+		} else {
+			encodedVal, encodeErr = handleGoType(v, k, &opts)
+		}
+		if encodeErr != nil {
+			if opts.collectError(encodeErr) {
+				continue
+			}
+			return nil, opts.conversionError(encodeErr)
 		}
 		encodedPayload[k] = encodedVal
 	}
+
+	if err := opts.conversionError(nil); err != nil {
+		return nil, err
+	}
 	resPayload["fields"] = encodedPayload
 	return resPayload, nil
-}
\ No newline at end of file
+}
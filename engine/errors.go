@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// Default limit of the errors, collected from a single payload.
+	defaultMaxErrors = 100
+)
+
+// Kind of the engine error. Kinds are errors themselves, so they could be matched with errors.Is -
+// errors.Is(err, engine.ErrInvalidValue).
+type ErrorKind string
+
+func (ek ErrorKind) Error() string {
+	return string(ek)
+}
+
+const (
+	// Firestore payload doesn't have the structure of the Firestore values (e.g. 'mapValue' without 'fields').
+	ErrInvalidStructure ErrorKind = "invalid structure"
+	// Value doesn't match its type (e.g. invalid timestamp or integer out of the 64-bit range).
+	ErrInvalidValue ErrorKind = "invalid value"
+	// Firestore type or the json value is not supported.
+	ErrUnsupportedType ErrorKind = "unsupported type"
+	// Value doesn't match the type, declared in the schema, or its path is not declared.
+	ErrSchemaMismatch ErrorKind = "schema mismatch"
+	// Payload violates the Firestore data model constraints (e.g. nested arrays).
+	ErrConstraintViolation ErrorKind = "constraint violation"
+	// Field transform sentinel is invalid or used outside of the write bodies.
+	ErrInvalidTransform ErrorKind = "invalid transform"
+	// Estimated size of the document exceeds the limit.
+	ErrDocumentTooLarge ErrorKind = "document too large"
+	// Payload as a whole can't be converted (e.g. it's not a json object).
+	ErrInvalidPayload ErrorKind = "invalid payload"
+	// File can't be read or written.
+	ErrIO ErrorKind = "io"
+	// Options or paths, provided by the user, are invalid.
+	ErrInvalidUsage ErrorKind = "invalid usage"
+	// File was not processed, since the run was stopped at the first failure (fail-fast).
+	ErrAborted ErrorKind = "aborted"
+	// Some of the files of the run were not converted.
+	ErrPartialFailure ErrorKind = "partial failure"
+	// None of the files of the run were converted.
+	ErrAllFailed ErrorKind = "all failed"
+)
+
+// Error of the engine. Path and FirestoreType are set for the errors of the specific values of the payload.
+type Error struct {
+	Kind ErrorKind
+	// Path of the value in the payload - 'profile/mapValue/fields/age' for the Firestore payloads and 'profile/age'
+	// for the plain ones. Array elements are referenced by the index - 'tags[0]'.
+	Path string
+	FirestoreType string
+	// File, the error relates to.
+	File string
+	Reason string
+	// Underlying error (e.g. the error of parsing the timestamp), if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	var message string
+	switch {
+	case e.Path != "" && e.FirestoreType != "":
+		message = generateErrorMessage(e.Path, e.FirestoreType, e.Reason)
+	case e.Path != "":
+		message = fmt.Sprintf("Structure under the path -> %s is invalid! Reason - %s", e.Path, e.Reason)
+	default:
+		message = e.Reason
+	}
+
+	if e.File != "" {
+		return fmt.Sprintf("File - %s: %s", e.File, message)
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Matches the error against its kind.
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind == e.Kind
+}
+
+func newError(kind ErrorKind, path string, firestoreType string, reason string) *Error {
+	return &Error{Kind: kind, Path: path, FirestoreType: firestoreType, Reason: reason}
+}
+
+// Wraps the underlying error, which message becomes the reason of the engine error.
+func wrapError(kind ErrorKind, path string, firestoreType string, err error) *Error {
+	return &Error{Kind: kind, Path: path, FirestoreType: firestoreType, Reason: err.Error(), Err: err}
+}
+
+// Creates the error, which relates to the whole file.
+func newFileError(kind ErrorKind, file string, reason string, err error) *Error {
+	return &Error{Kind: kind, File: file, Reason: reason, Err: err}
+}
+
+// Errors of all the invalid paths, found in the payload, when errors are collected ('CollectErrors' option).
+type MultiError struct {
+	Errors []error
+	// True, in case, if the limit of the errors was reached, so the rest of the payload was not checked.
+	Truncated bool
+}
+
+func (me *MultiError) Error() string {
+	lines := []string{fmt.Sprintf("Payload contains %d error(s):", len(me.Errors))}
+	for _, err := range me.Errors {
+		lines = append(lines, "  - " + err.Error())
+	}
+	if me.Truncated {
+		lines = append(lines, "  Limit of the errors was reached, so the rest of the payload was not checked.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (me *MultiError) Unwrap() []error {
+	return me.Errors
+}
+
+// Collects the errors of a single conversion, so the whole payload is walked, instead of failing on the first error.
+type errorCollector struct {
+	errs []error
+	// Maximum amount of the collected errors. Unlimited, if 0.
+	maxErrors int
+	truncated bool
+}
+
+// Records the error. Returns false, in case, if the limit of the errors is reached, so the conversion should be aborted.
+func (ec *errorCollector) add(err error) bool {
+	if ec.truncated {
+		return false
+	}
+
+	// Joined errors (e.g. constraint violations) are recorded one by one.
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, joinedErr := range joined.Unwrap() {
+			if !ec.add(joinedErr) {
+				return false
+			}
+		}
+		return true
+	}
+
+	ec.errs = append(ec.errs, err)
+	if ec.maxErrors > 0 && len(ec.errs) >= ec.maxErrors {
+		ec.truncated = true
+		return false
+	}
+	return true
+}
+
+// Returns the collected errors, sorted by their messages, as a single error. Nil, if no errors were collected.
+func (ec *errorCollector) err() error {
+	if len(ec.errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(ec.errs, func(i, j int) bool {
+		return ec.errs[i].Error() < ec.errs[j].Error()
+	})
+	return &MultiError{Errors: ec.errs, Truncated: ec.truncated}
+}
+
+// Starts collecting the errors of a new conversion, in case, if the 'CollectErrors' option is set.
+func (o *Options) startCollecting() {
+	o.errs = nil
+	if o.CollectErrors {
+		o.errs = &errorCollector{errs: []error{}, maxErrors: o.MaxErrors}
+	}
+}
+
+// Records the error, in case, if the errors are collected. Returns false, in case, if the error should abort the conversion.
+func (o *Options) collectError(err error) bool {
+	return o.errs != nil && o.errs.add(err)
+}
+
+// Returns the error of the conversion - either the one provided or all the collected ones.
+func (o *Options) conversionError(err error) error {
+	if o.errs == nil {
+		return err
+	}
+	if err != nil {
+		o.errs.add(err)
+	}
+	return o.errs.err()
+}
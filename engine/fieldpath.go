@@ -0,0 +1,31 @@
+package engine
+
+import "strings"
+
+// Wraps the field name into backticks, in case, if it contains characters, other than letters, digits and underscores
+// (or starts with a digit), as it's required by the Firestore field paths - 'profile.`first.name`'.
+func quoteFieldPathSegment(key string) string {
+	isSimple := key != ""
+	for i, c := range key {
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && !(i > 0 && c >= '0' && c <= '9') {
+			isSimple = false
+			break
+		}
+	}
+	if isSimple {
+		return key
+	}
+
+	escaped := strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(key)
+	return "`" + escaped + "`"
+}
+
+// Joins the field names into the Firestore field path - ['profile', 'first.name'] -> 'profile.`first.name`'.
+func formatFieldPath(segments []string) string {
+	quoted := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		quoted = append(quoted, quoteFieldPathSegment(segment))
+	}
+	return strings.Join(quoted, ".")
+}
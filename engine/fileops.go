@@ -1,10 +1,17 @@
 package engine
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"github.com/mvksxm/firestore-json-convert/utils"
 )
 
 
@@ -13,41 +20,107 @@ type FileIO struct {
 	outputPath string
 }
 
-func (fo *FileIO) ReadInput() (map[string]interface{}, error) {
-	payload := make(map[string]interface{})
-
-	content, err := os.ReadFile(fo.inputPath)
+// Reads the json structure from the input file. It's either an object (a single document) or an array (e.g. 'runQuery' response).
+// Input path '-' stands for the standard input.
+func (fo *FileIO) ReadInput() (interface{}, error) {
+	var payload interface{}
+	var content []byte
+	var err error
+
+	if utils.IsStdStream(fo.inputPath) {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(fo.inputPath)
+	}
 	if err != nil {
 		slog.Warn(fmt.Sprintf("There was an issue with reading an input file - %s. It will be skipped, for now.", fo.inputPath))
-		return nil, err
+		return nil, newFileError(ErrIO, fo.inputPath, "There was an issue with reading an input file. Err - " + err.Error(), err)
 	}
 
-	err = json.Unmarshal(content, &payload)
+	// Numbers are kept as json.Number, so 64-bit integers are not rounded to float64.
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	err = decodeJSON(decoder, &payload)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("Provided input file - %s contains an invalid json structure!. It will be skipped, for now.", fo.inputPath))
-		return nil, err
+		return nil, newFileError(ErrInvalidPayload, fo.inputPath, "Input file contains an invalid json structure. Err - " + err.Error(), err)
 	}
 
 	return payload, nil
 }
 
-func (fo *FileIO) WriteOutput(payload map[string]interface{}) error {
+// Decodes the single json value, which is not followed by any other data.
+func decodeJSON(decoder *json.Decoder, target interface{}) error {
+	if err := decoder.Decode(target); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("json value is followed by the trailing data")
+	}
+	return nil
+}
+
+// Writes the payload to the output file. Output path '-' stands for the standard output.
+func (fo *FileIO) WriteOutput(payload interface{}) error {
 
 	byteArr, err := json.Marshal(payload)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("There was an issue with converting a payload of the file %s to a byte array. Err - %s", fo.inputPath, err.Error()))
-		return err
+		return newFileError(ErrInvalidPayload, fo.inputPath, "Payload can't be converted to a byte array. Err - " + err.Error(), err)
+	}
+
+	if fo.IsStdout() {
+		_, err = os.Stdout.Write(append(byteArr, '\n'))
+	} else {
+		err = os.WriteFile(fo.outputPath, byteArr, 0777)
 	}
-	
-	err = os.WriteFile(fo.outputPath, byteArr, 0777)
 	if err != nil {
 		slog.Warn(fmt.Sprintf("There was an issue with writing a payload to the output file - %s. Err - %s", fo.outputPath, err.Error()))
-		return err
+		return newFileError(ErrIO, fo.outputPath, "There was an issue with writing a payload to the output file. Err - " + err.Error(), err)
 
 	}
 	return nil
 }
 
+// Returns the path of the output file chunk, numbered from 1 - 'output.json' -> 'output_1.json' for the chunk with the index 0.
+func chunkPath(path string, idx int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), idx + 1, ext)
+}
+
+// Writes each of the payloads to a separate output file. Single payload is written to the output path as is.
+// Payloads, written to the standard output, are separated by the new lines.
+func (fo *FileIO) WriteOutputChunks(payloads []interface{}) error {
+	if len(payloads) == 1 {
+		return fo.WriteOutput(payloads[0])
+	}
+
+	if fo.IsStdout() {
+		for _, payload := range payloads {
+			if err := fo.WriteOutput(payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, payload := range payloads {
+		chunkIO := NewFileIO(fo.inputPath, chunkPath(fo.outputPath, i))
+		if err := chunkIO.WriteOutput(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fo *FileIO) IsStdin() bool {
+	return utils.IsStdStream(fo.inputPath)
+}
+
+func (fo *FileIO) IsStdout() bool {
+	return utils.IsStdStream(fo.outputPath)
+}
+
 func (fo *FileIO) GetInputPath() string {
 	return fo.inputPath
 }
@@ -57,6 +130,45 @@ func (fo *FileIO) GetOutputPath() string {
 }
 
 
+// Reads the mapping file of the input paths and their respective output paths - {"input.json": "output.json"}.
+// Relative paths are resolved against the directory of the mapping file. Pairs are sorted by the input paths.
+func LoadPathMapping(path string) ([]string, []string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, newFileError(ErrIO, path, "There was an issue with reading the mapping file. Err - " + err.Error(), err)
+	}
+
+	mapping := map[string]string{}
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, nil, newFileError(ErrInvalidUsage, path, "Mapping file should contain a json object of the input and output paths. Err - " + err.Error(), err)
+	}
+
+	if len(mapping) == 0 {
+		return nil, nil, newFileError(ErrInvalidUsage, path, "Mapping file does not contain any paths", nil)
+	}
+
+	resolve := func(mappedPath string) string {
+		if utils.IsStdStream(mappedPath) || filepath.IsAbs(mappedPath) {
+			return mappedPath
+		}
+		return filepath.Join(filepath.Dir(path), mappedPath)
+	}
+
+	inputPaths := make([]string, 0, len(mapping))
+	for inputPath := range mapping {
+		inputPaths = append(inputPaths, inputPath)
+	}
+	sort.Strings(inputPaths)
+
+	outputPaths := make([]string, 0, len(mapping))
+	for i, inputPath := range inputPaths {
+		outputPaths = append(outputPaths, resolve(mapping[inputPath]))
+		inputPaths[i] = resolve(inputPath)
+	}
+
+	return inputPaths, outputPaths, nil
+}
+
 func NewFileIO(inputPath string, outputPath string) *FileIO {
 	return &FileIO{
 		inputPath: inputPath,
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+var (
+	// Firestore type keys and their respective schema types.
+	firestoreSchemaTypes = func() map[string]string {
+		reversed := make(map[string]string, len(schemaTypes))
+		for schemaType, typeKey := range schemaTypes {
+			if typeKey != "" {
+				reversed[typeKey] = schemaType
+			}
+		}
+		return reversed
+	}()
+)
+
+// Statistics of the values, observed under a single field path.
+type FieldStats struct {
+	// Amount of the values found under the field path.
+	Count int `json:"count"`
+	// Observed schema types and the amount of values of each type.
+	Types map[string]int `json:"types"`
+	// True, in case, if the field is absent in some of the maps (documents), it belongs to.
+	Optional bool `json:"optional"`
+	// Observed schema types of the array elements. Populated only for the array fields.
+	ElementTypes map[string]int `json:"elementTypes,omitempty"`
+
+	parentPath string
+	isElement bool
+}
+
+// Union schema of all the documents added to it.
+type InferredSchema struct {
+	Documents int `json:"documents"`
+	Fields map[string]*FieldStats `json:"fields"`
+}
+
+func (is *InferredSchema) getStats(path string, parentPath string, isElement bool) *FieldStats {
+	stats, ok := is.Fields[path]
+	if !ok {
+		stats = &FieldStats{Types: map[string]int{}, parentPath: parentPath, isElement: isElement}
+		is.Fields[path] = stats
+	}
+	return stats
+}
+
+// Registers the Firestore value, found under the field path provided, and all of its children.
+func (is *InferredSchema) walk(encodedVal interface{}, path string, parentPath string, isElement bool) {
+	stats := is.getStats(path, parentPath, isElement)
+	stats.Count++
+
+	schemaType := firestoreSchemaTypes[encodedTypeKey(encodedVal)]
+	stats.Types[schemaType]++
+
+	encodedMap, _ := encodedVal.(map[string]interface{})
+	switch schemaType {
+	case "map":
+		fields, _ := encodedMap["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})
+		for k, v := range fields {
+			is.walk(v, path + "." + formatSchemaSegment(k), path, false)
+		}
+	case "array":
+		if stats.ElementTypes == nil {
+			stats.ElementTypes = map[string]int{}
+		}
+		values, _ := encodedMap["arrayValue"].(map[string]interface{})["values"].([]interface{})
+		for _, v := range values {
+			stats.ElementTypes[firestoreSchemaTypes[encodedTypeKey(v)]]++
+			is.walk(v, path + schemaArrayElem, path, true)
+		}
+	}
+}
+
+// Adds the document, either in the Firestore or in the plain JSON format, to the schema.
+func (is *InferredSchema) AddDocument(payload map[string]interface{}) error {
+	var fields map[string]interface{}
+
+	// Format of the document is detected the same way, as for the conversion, so the invalid Firestore payload
+	// is reported, instead of being treated as a plain JSON one.
+	direction, reason := DetectDirection(payload)
+	if direction == DirectionDecode {
+		// Firestore payload is validated by decoding, so its types could be taken as is.
+		if _, decodeErr := DecodeFromFirestore(payload); decodeErr != nil {
+			return newError(ErrInvalidPayload, "", "", fmt.Sprintf(
+				"Document is a Firestore payload, since %s, but it can't be decoded. Reason - %s", reason, decodeErr.Error(),
+			))
+		}
+		// Fields are missing, in case, if it's an empty document.
+		fields, _ = payload["fields"].(map[string]interface{})
+	} else {
+		encodedPayload, encodeErr := EncodeToFirestore(payload)
+		if encodeErr != nil {
+			return newError(ErrInvalidPayload, "", "", fmt.Sprintf(
+				"Document is a plain JSON payload, since %s, but it can't be encoded. Reason - %s", reason, encodeErr.Error(),
+			))
+		}
+		fields = encodedPayload["fields"].(map[string]interface{})
+	}
+
+	is.Documents++
+	for k, v := range fields {
+		is.walk(v, formatSchemaSegment(k), "", false)
+	}
+
+	is.refreshOptionality()
+	return nil
+}
+
+func (is *InferredSchema) refreshOptionality() {
+	for _, stats := range is.Fields {
+		if stats.isElement {
+			continue
+		}
+		parentCount := is.Documents
+		if stats.parentPath != "" {
+			parentCount = is.Fields[stats.parentPath].Types["map"]
+		}
+		stats.Optional = stats.Count < parentCount
+	}
+}
+
+// Returns the schema manifest, that could be used for the strict encoding ('--schema' CLI flag).
+// Fields, that were observed with the different types, are declared as 'any'.
+func (is *InferredSchema) Manifest() map[string]string {
+	manifest := map[string]string{}
+
+	paths := make([]string, 0, len(is.Fields))
+	for path := range is.Fields {
+		paths = append(paths, path)
+	}
+	// Parents go before their children, so the children of the 'any' fields could be skipped.
+	sort.Strings(paths)
+
+	anyPaths := []string{}
+	for _, path := range paths {
+		isAnyChild := false
+		for _, anyPath := range anyPaths {
+			if strings.HasPrefix(path, anyPath + ".") || strings.HasPrefix(path, anyPath + schemaArrayElem) {
+				isAnyChild = true
+				break
+			}
+		}
+		if isAnyChild {
+			continue
+		}
+
+		stats := is.Fields[path]
+		fieldType := schemaAnyType
+		if len(stats.Types) == 1 {
+			for schemaType := range stats.Types {
+				fieldType = schemaType
+			}
+		}
+		if fieldType == schemaAnyType {
+			anyPaths = append(anyPaths, path)
+		}
+		manifest[path] = fieldType
+	}
+
+	return manifest
+}
+
+func NewInferredSchema() *InferredSchema {
+	return &InferredSchema{
+		Documents: 0,
+		Fields: map[string]*FieldStats{},
+	}
+}
+
+// Reads the documents from the files provided and infers their union schema.
+// Files, that can't be read or converted, are skipped.
+func InferSchema(inputPaths []string) (*InferredSchema, error) {
+	is := NewInferredSchema()
+
+	for _, inputPath := range inputPaths {
+		fileIO := NewFileIO(inputPath, "")
+		payload, err := fileIO.ReadInput()
+		if err != nil {
+			continue
+		}
+		payloadMap, ok := payload.(map[string]interface{})
+		if !ok {
+			slog.Warn(fmt.Sprintf("File - %s will be skipped, since it does not contain a single json object.", inputPath))
+			continue
+		}
+		if err := is.AddDocument(payloadMap); err != nil {
+			slog.Warn(fmt.Sprintf("File - %s will be skipped, since its schema can't be inferred. Reason - %s", inputPath, err.Error()))
+		}
+	}
+
+	if is.Documents == 0 {
+		return nil, newError(ErrInvalidPayload, "", "", "Schema can't be inferred, since none of the files provided contain a valid document.")
+	}
+
+	return is, nil
+}
@@ -0,0 +1,174 @@
+package engine
+
+import "fmt"
+
+// Defines, how the collections of the decoded documents (e.g. from the 'runQuery' response) are represented.
+type CollectionFormatMode string
+
+const (
+	// {"{document_id}": {...}}
+	CollectionFormatMap CollectionFormatMode = "map"
+	// [{...}, {...}]
+	CollectionFormatArray CollectionFormatMode = "array"
+)
+
+// Defines, how the NaN, Infinity and -Infinity doubles are represented in the decoded plain JSON.
+type SpecialDoublesMode string
+
+const (
+	// {"$double": "NaN"} - can be encoded back into the 'doubleValue'.
+	SpecialDoublesTagged SpecialDoublesMode = "tagged"
+	// "NaN" - encoded back into the 'stringValue'.
+	SpecialDoublesString SpecialDoublesMode = "string"
+	// null - encoded back into the 'nullValue'.
+	SpecialDoublesNull SpecialDoublesMode = "null"
+)
+
+// Options control the representation of the values, that don't have a native plain JSON counterpart.
+type Options struct {
+	// Direction of the conversion. In 'auto' mode, it's detected from the structure of each payload.
+	Direction DirectionMode
+
+	// When true, vectors are decoded into the {"$vector": [...]} form instead of a plain array of numbers.
+	// The tagged form can be encoded back into the Firestore vector, while the plain array becomes an 'arrayValue'.
+	TagVectors bool
+
+	// When true, decoded doubles always keep a fraction part (e.g. 3.0) and numbers with a fraction or an exponent
+	// are always encoded as doubles, so decoding and encoding the payload back doesn't change the Firestore types.
+	PreserveNumberTypes bool
+
+	// Representation of the NaN, Infinity and -Infinity doubles in the decoded plain JSON.
+	SpecialDoubles SpecialDoublesMode
+
+	// When true, decoded values, which type would be guessed differently by the encoder, are wrapped into type hints
+	// (e.g. {"$string": "abcd="}), so they survive the round trip. Type hints are always honoured by the encoder.
+	// Vectors are tagged as well, regardless of TagVectors.
+	EmitTypeHints bool
+
+	// When set, payloads are encoded strictly according to the schema, instead of guessing the Firestore types.
+	Schema *Schema
+
+	// Keys of the plain JSON, the document metadata is decoded into and encoded from. Metadata is skipped, if empty.
+	// Document name is built from the ID and the collection path, in case, if only ID is provided.
+	NameKey string
+	IDKey string
+	CreateTimeKey string
+	UpdateTimeKey string
+
+	// Path of the collection, documents belong to - 'projects/{project_id}/databases/{database_id}/documents/{collection_path}'.
+	CollectionPath string
+
+	// Representation of the decoded document collections.
+	CollectionFormat CollectionFormatMode
+
+	// When set, the plain JSON collection is encoded into the 'commit' or 'batchWrite' request bodies.
+	WriteBody WriteBodyMode
+	// 'currentDocument' precondition of the generated writes.
+	Precondition PreconditionMode
+	// Defines, whether the generated writes mask the fields of their documents or replace the whole documents.
+	UpdateMask UpdateMaskMode
+
+	// Limit of the estimated size of the encoded documents in bytes and the action, taken when it is exceeded.
+	MaxDocumentSize int
+	Oversize OversizeMode
+
+	// When true, the whole payload is walked and the errors of all the invalid paths are returned as a MultiError,
+	// instead of failing on the first one. Amount of the collected errors is limited by MaxErrors (unlimited, if 0).
+	CollectErrors bool
+	MaxErrors int
+
+	// Errors of the current conversion, in case, if they are collected.
+	errs *errorCollector
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Direction: DirectionAuto,
+		TagVectors: false,
+		PreserveNumberTypes: false,
+		SpecialDoubles: SpecialDoublesTagged,
+		EmitTypeHints: false,
+		Schema: nil,
+		NameKey: "",
+		IDKey: "",
+		CreateTimeKey: "",
+		UpdateTimeKey: "",
+		CollectionPath: "",
+		CollectionFormat: CollectionFormatMap,
+		WriteBody: WriteBodyNone,
+		Precondition: PreconditionNone,
+		UpdateMask: UpdateMaskFields,
+		MaxDocumentSize: defaultMaxDocumentSize,
+		Oversize: OversizeFail,
+		CollectErrors: false,
+		MaxErrors: defaultMaxErrors,
+	}
+}
+
+func (o *Options) validate() error {
+	switch o.Direction {
+	case DirectionAuto, DirectionEncode, DirectionDecode:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Direction -> '%s' is not supported. Supported directions: encode, decode, auto.", o.Direction))
+	}
+
+	if o.Direction == DirectionDecode && o.WriteBody != WriteBodyNone {
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Write bodies ('%s') can only be encoded, while the direction is -> '%s'.", o.WriteBody, o.Direction))
+	}
+
+	switch o.SpecialDoubles {
+	case SpecialDoublesTagged, SpecialDoublesString, SpecialDoublesNull:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Special doubles mode -> '%s' is not supported. Supported modes: tagged, string, null.", o.SpecialDoubles))
+	}
+
+	switch o.CollectionFormat {
+	case CollectionFormatMap, CollectionFormatArray:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Collection format -> '%s' is not supported. Supported formats: map, array.", o.CollectionFormat))
+	}
+
+	switch o.WriteBody {
+	case WriteBodyNone, WriteBodyCommit, WriteBodyBatchWrite:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Write body -> '%s' is not supported. Supported bodies: commit, batchWrite.", o.WriteBody))
+	}
+
+	switch o.Precondition {
+	case PreconditionNone, PreconditionExists, PreconditionMissing:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Precondition -> '%s' is not supported. Supported preconditions: none, exists, missing.", o.Precondition))
+	}
+
+	switch o.UpdateMask {
+	case UpdateMaskFields, UpdateMaskNone:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Update mask mode -> '%s' is not supported. Supported modes: fields, none.", o.UpdateMask))
+	}
+
+	switch o.Oversize {
+	case OversizeWarn, OversizeFail:
+	default:
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Oversize action -> '%s' is not supported. Supported actions: warn, fail.", o.Oversize))
+	}
+
+	if o.MaxErrors < 0 {
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Maximum amount of the errors -> %d can't be negative.", o.MaxErrors))
+	}
+
+	if o.MaxDocumentSize <= 0 {
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Maximum document size -> %d should be a positive amount of bytes.", o.MaxDocumentSize))
+	}
+
+	if o.WriteBody != WriteBodyNone && o.CollectionPath == "" {
+		return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Collection path (--collection CLI flag) is required, in order to generate the '%s' request body.", o.WriteBody))
+	}
+
+	if o.CollectionPath != "" {
+		if _, err := handleCollectionPath(o.CollectionPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// Checks, whether the encoded value is a regular map, which fields could be diffed one by one.
+func isDiffableMap(encodedVal interface{}) (map[string]interface{}, bool) {
+	encodedMap, ok := encodedVal.(map[string]interface{})
+	if !ok || len(encodedMap) != 1 {
+		return nil, false
+	}
+	mapValue, ok := encodedMap["mapValue"]
+	if !ok || isFirestoreVector(mapValue) {
+		return nil, false
+	}
+	fields, ok := mapValue.(map[string]interface{})["fields"].(map[string]interface{})
+	return fields, ok
+}
+
+// Compares the encoded fields of the old and the new document. Returns the changed fields (nested maps contain only
+// the changed fields as well) and the paths of all the changed and deleted fields.
+func diffFields(oldFields map[string]interface{}, newFields map[string]interface{}, segments []string) (map[string]interface{}, [][]string) {
+	changedFields := map[string]interface{}{}
+	changedPaths := [][]string{}
+
+	for k := range oldFields {
+		if _, found := newFields[k]; !found {
+			changedPaths = append(changedPaths, append(slices.Clone(segments), k))
+		}
+	}
+
+	for k, newVal := range newFields {
+		fieldSegments := append(slices.Clone(segments), k)
+		oldVal, found := oldFields[k]
+
+		// Nested maps are diffed field by field, so the untouched fields are not overwritten.
+		oldMap, isOldMap := isDiffableMap(oldVal)
+		newMap, isNewMap := isDiffableMap(newVal)
+		if found && isOldMap && isNewMap && len(newMap) > 0 {
+			nestedFields, nestedPaths := diffFields(oldMap, newMap, fieldSegments)
+			if len(nestedPaths) > 0 {
+				changedFields[k] = map[string]interface{}{"mapValue": map[string]interface{}{"fields": nestedFields}}
+				changedPaths = append(changedPaths, nestedPaths...)
+			}
+			continue
+		}
+
+		if !found || !reflect.DeepEqual(oldVal, newVal) {
+			changedFields[k] = newVal
+			changedPaths = append(changedPaths, fieldSegments)
+		}
+	}
+
+	return changedFields, changedPaths
+}
+
+// Builds the body of the document PATCH request, that turns the old document into the new one - the changed fields
+// and the 'updateMask.fieldPaths' of all the changed and deleted fields.
+func BuildPatchBody(oldDoc map[string]interface{}, newDoc map[string]interface{}, opts Options) (map[string]interface{}, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	// Patch body is always encoded, so the options of the other conversions can't be applied to it.
+	if opts.Direction == DirectionDecode || opts.WriteBody != WriteBodyNone {
+		return nil, newError(ErrInvalidUsage, "", "", "Patch body can only be encoded, so neither the 'decode' direction, nor the write bodies could be set.")
+	}
+
+	encodedOld, err := EncodeToFirestoreWithOptions(oldDoc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedNew, err := EncodeToFirestoreWithOptions(newDoc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	changedFields, changedPaths := diffFields(
+		encodedOld["fields"].(map[string]interface{}),
+		encodedNew["fields"].(map[string]interface{}),
+		[]string{},
+	)
+
+	fieldPaths := make([]string, 0, len(changedPaths))
+	for _, segments := range changedPaths {
+		fieldPaths = append(fieldPaths, formatFieldPath(segments))
+	}
+	sort.Strings(fieldPaths)
+
+	patchBody := map[string]interface{}{
+		"fields": changedFields,
+		"updateMask": map[string]interface{}{"fieldPaths": fieldPaths},
+	}
+	if name, found := encodedNew[documentNameKey]; found {
+		patchBody[documentNameKey] = name
+	}
+
+	return patchBody, nil
+}
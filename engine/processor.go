@@ -1,66 +1,132 @@
 package engine
 
-import (
-	"fmt"
-	"log/slog"
-)
-
 type Processor struct {
-	payload map[string]interface{}
+	payload interface{}
+	opts Options
+	// Estimated sizes of the documents, encoded by the last conversion.
+	sizes []*DocumentSize
+	// Amount of the documents, converted by the last conversion.
+	documents int
+	// Names of the documents, reported as missing by the 'batchGet' response of the last conversion.
+	missing []string
+	// Direction of the last conversion and the reason, it was chosen for.
+	direction DirectionMode
+	directionReason string
 }
 
-func (prc *Processor) Convert() (map[string]interface{}, error) {
-	decodedPayload, decodeErr := DecodeFromFirestore(prc.payload)
-	if decodeErr == nil {
-		return decodedPayload, nil
-	}
+// Returns the direction of the last conversion and the reason, it was chosen for.
+func (prc *Processor) Direction() (DirectionMode, string) {
+	return prc.direction, prc.directionReason
+}
+
+// Returns the estimated sizes of the documents, encoded by the last conversion. Empty, if the payload was decoded.
+func (prc *Processor) DocumentSizes() []*DocumentSize {
+	return prc.sizes
+}
+
+// Returns the amount of the documents, converted by the last conversion - documents of the Firestore API response,
+// writes of the request bodies or a single document otherwise.
+func (prc *Processor) Documents() int {
+	return prc.documents
+}
 
-	slog.Warn(
-		fmt.Sprintf(
-			"Payload provided by the user can't be decoded from Firestore format, due to the following reason - %s.",
-			decodeErr.Error(),
-		),
-	)
+// Returns the names of the documents, that were requested, but not found, according to the 'batchGet' response.
+func (prc *Processor) MissingDocuments() []string {
+	return prc.missing
+}
 
-	fmt.Println("Proceeding with checking, whether payload is suitable encoding into the Firestore format.")
+func (prc *Processor) payloadMap() (map[string]interface{}, error) {
+	payloadMap, ok := prc.payload.(map[string]interface{})
+	if !ok {
+		return nil, newError(ErrInvalidPayload, "", "", "Payload provided is not a json object, so it can't be treated as a single document.")
+	}
+	return payloadMap, nil
+}
 
-	encodedPayload, encodeErr := EncodeToFirestore(prc.payload)
-	if encodeErr == nil {
-		return encodedPayload, nil
+func (prc *Processor) decodeResponse() (interface{}, error) {
+	decodedDocs, missing, err := DecodeResponses([]interface{}{prc.payload}, prc.opts)
+	if err != nil {
+		return nil, err
 	}
+	reportMissingDocuments(missing)
+	prc.documents = collectionSize(decodedDocs)
+	prc.missing = missing
+	return decodedDocs, nil
+}
 
-	slog.Warn(
-		fmt.Sprintf(
-			`Payload provided by the user can't be encoded from Firestore format, due to the following reason - %s.`,
-			encodeErr.Error(),
-		),
-	)
-
-	return nil, fmt.Errorf(
-		`Can't decode the payload from Firestore type due to the following error -> %s.
-		At the same time, can't encode the payload to the Firestore type due to the following error -> %s`,
-		decodeErr, encodeErr,
-	)
+// Converts the payload in the direction of the options. In 'auto' mode, direction is detected from the payload structure.
+func (prc *Processor) Convert() (interface{}, error) {
+	prc.direction, prc.directionReason = prc.opts.Direction, "it is set explicitly"
+	if prc.opts.Direction == DirectionAuto {
+		prc.direction, prc.directionReason = DetectDirection(prc.payload)
+	}
+
+	if prc.direction == DirectionDecode {
+		return prc.ConvertFromFirestore()
+	}
+	return prc.ConvertToFirestore()
 }
 
 func (prc *Processor) ConvertToFirestore() (interface{}, error) {
-	encodedPayload, encodeErr := EncodeToFirestore(prc.payload)
+	prc.sizes, prc.documents = nil, 0
+	payloadMap, err := prc.payloadMap()
+	if err != nil {
+		return nil, err
+	}
+
+	encodedPayload, encodeErr := EncodeToFirestoreWithOptions(payloadMap, prc.opts)
 	if encodeErr != nil {
 		return nil, encodeErr
 	}
+	prc.sizes = []*DocumentSize{EstimateDocumentSize(encodedPayload)}
+	prc.documents = 1
 	return encodedPayload, nil
 }
 
 func (prc *Processor) ConvertFromFirestore() (interface{}, error) {
-	decodedPayload, decodeErr := DecodeFromFirestore(prc.payload)
+	prc.sizes, prc.documents, prc.missing = nil, 0, nil
+	if isDocumentsResponse(prc.payload) {
+		return prc.decodeResponse()
+	}
+
+	payloadMap, err := prc.payloadMap()
+	if err != nil {
+		return nil, err
+	}
+
+	decodedPayload, decodeErr := DecodeFromFirestoreWithOptions(payloadMap, prc.opts)
 	if decodeErr != nil {
 		return nil, decodeErr
 	}
+	prc.documents = 1
 	return decodedPayload, nil
 }
 
-func NewProcessor(payload map[string]interface{}) *Processor {
+// Encodes the plain JSON collection ({"{document_id}": {...}}) into the 'commit' or 'batchWrite' request bodies.
+func (prc *Processor) ConvertToWriteBodies() ([]interface{}, error) {
+	prc.direction, prc.directionReason = DirectionEncode, "write bodies are generated"
+	prc.sizes, prc.documents = nil, 0
+	payloadMap, err := prc.payloadMap()
+	if err != nil {
+		return nil, err
+	}
+
+	writeBodies, err := BuildWriteBodies(payloadMap, prc.opts)
+	if err != nil {
+		return nil, err
+	}
+	prc.sizes = estimateWriteBodySizes(writeBodies)
+	prc.documents = len(prc.sizes)
+	return writeBodies, nil
+}
+
+func NewProcessor(payload interface{}) *Processor {
+	return NewProcessorWithOptions(payload, DefaultOptions())
+}
+
+func NewProcessorWithOptions(payload interface{}, opts Options) *Processor {
 	return &Processor{
 		payload: payload,
+		opts: opts,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"github.com/mvksxm/firestore-json-convert/utils"
+)
+
+// Format of the report, produced by the MultipleConverter.
+type ReportFormat string
+
+const (
+	// Human readable logs (and the summary in tree mode) only.
+	ReportText ReportFormat = "text"
+	// Single json report, written once all the files are processed.
+	ReportJSON ReportFormat = "json"
+)
+
+// Outcome of the input file.
+type FileStatus string
+
+const (
+	FileConverted FileStatus = "converted"
+	// File was processed, but its output was not written.
+	FileFailed FileStatus = "failed"
+	// File was not processed, due to its invalid path or payload.
+	FileSkipped FileStatus = "skipped"
+)
+
+type ReportError struct {
+	Kind ErrorKind `json:"kind,omitempty"`
+	Path string `json:"path,omitempty"`
+	FirestoreType string `json:"firestoreType,omitempty"`
+	Message string `json:"message"`
+}
+
+type FileReport struct {
+	Input string `json:"input"`
+	Output string `json:"output,omitempty"`
+	Status FileStatus `json:"status"`
+	Direction DirectionMode `json:"direction,omitempty"`
+	DirectionReason string `json:"directionReason,omitempty"`
+	// Amount of the converted documents (e.g. documents of the 'runQuery' response or writes of the request bodies).
+	Documents int `json:"documents"`
+	// Names of the documents, that were requested, but not found, according to the 'batchGet' response.
+	Missing []string `json:"missing,omitempty"`
+	Errors []ReportError `json:"errors"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+type ReportSummary struct {
+	Files int `json:"files"`
+	Converted int `json:"converted"`
+	Failed int `json:"failed"`
+	// Files, that were skipped, due to their invalid paths or payloads, or filtered out by the include/exclude patterns.
+	Skipped int `json:"skipped"`
+	Documents int `json:"documents"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+// Report of the MultipleConverter run - outcome of each input file and the overall summary.
+// Errors, that are not related to a specific file (e.g. invalid options), are listed separately.
+type RunReport struct {
+	Mode string `json:"mode"`
+	Files []*FileReport `json:"files"`
+	Errors []ReportError `json:"errors"`
+	Summary ReportSummary `json:"summary"`
+}
+
+func newFileReport(inputPath string, outputPath string) *FileReport {
+	return &FileReport{Input: inputPath, Output: outputPath, Errors: []ReportError{}}
+}
+
+// Records the outcome of the file. File is failed, in case, if the error is provided, and converted otherwise.
+func (fr *FileReport) finish(err error, duration time.Duration) {
+	fr.DurationMs = durationMs(duration)
+	if fr.Status == FileSkipped {
+		return
+	}
+	fr.Status = FileConverted
+	if err != nil {
+		fr.Status = FileFailed
+		fr.Errors = append(fr.Errors, reportErrors(err)...)
+	}
+}
+
+func (fr *FileReport) skip(err error) {
+	fr.Status = FileSkipped
+	fr.Errors = append(fr.Errors, reportErrors(err)...)
+}
+
+// Fills the summary from the reports of the files. Skipped files, which reports are not listed (e.g. filtered ones),
+// are added to the summary as well.
+func (rr *RunReport) finish(err error, unlisted int, duration time.Duration) {
+	if err != nil {
+		rr.Errors = append(rr.Errors, reportErrors(err)...)
+	}
+
+	// Files are listed in the same order each run, regardless of the order, they were processed in.
+	sort.SliceStable(rr.Files, func(i, j int) bool {
+		return rr.Files[i].Input < rr.Files[j].Input
+	})
+
+	rr.Summary = ReportSummary{Files: len(rr.Files), Skipped: unlisted, DurationMs: durationMs(duration)}
+	for _, fr := range rr.Files {
+		switch fr.Status {
+		case FileConverted:
+			rr.Summary.Converted++
+			rr.Summary.Documents += fr.Documents
+		case FileFailed:
+			rr.Summary.Failed++
+		case FileSkipped:
+			rr.Summary.Skipped++
+		}
+	}
+}
+
+// Returns the outcome of the run - nil, in case, if all the listed files were converted, ErrPartialFailure,
+// in case, if some of them were not, and ErrAllFailed otherwise. Filtered out files don't affect the outcome.
+func (rr *RunReport) outcome() error {
+	notConverted := 0
+	for _, fr := range rr.Files {
+		if fr.Status != FileConverted {
+			notConverted++
+		}
+	}
+
+	switch notConverted {
+	case 0:
+		return nil
+	case len(rr.Files):
+		return newError(ErrAllFailed, "", "", fmt.Sprintf("None of the %d file(s) were converted.", notConverted))
+	}
+	return newError(ErrPartialFailure, "", "", fmt.Sprintf("%d of %d file(s) were not converted.", notConverted, len(rr.Files)))
+}
+
+// Writes the report to the file. Path '-' or an empty one stands for the standard output.
+func (rr *RunReport) write(path string) error {
+	if path == "" {
+		path = utils.StdStreamPath
+	}
+	return NewFileIO("", path).WriteOutput(rr)
+}
+
+// Splits the error into the report entries. Collected and joined errors (e.g. constraint violations) are listed one by one.
+func reportErrors(err error) []ReportError {
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		entries := []ReportError{}
+		for _, joinedErr := range joined.Unwrap() {
+			entries = append(entries, reportErrors(joinedErr)...)
+		}
+		return entries
+	}
+
+	entry := ReportError{Message: err.Error()}
+	var engineErr *Error
+	if errors.As(err, &engineErr) {
+		entry.Kind = engineErr.Kind
+		entry.Path = engineErr.Path
+		entry.FirestoreType = engineErr.FirestoreType
+	}
+	return []ReportError{entry}
+}
+
+func durationMs(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+func (rf ReportFormat) validate() error {
+	switch rf {
+	case ReportText, ReportJSON:
+		return nil
+	}
+	return newError(ErrInvalidUsage, "", "", fmt.Sprintf("Report format -> '%s' is not supported. Supported formats: text, json.", rf))
+}
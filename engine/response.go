@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+var (
+	// Keys, that might appear in the elements of the 'runQuery' response stream.
+	runQueryKeys = []string {
+		"document",
+		"readTime",
+		"skippedResults",
+		"transaction",
+		"done",
+		"explainMetrics",
+	}
+
+	// Keys, that might appear in the elements of the 'batchGet' response stream.
+	batchGetKeys = []string {
+		"found",
+		"missing",
+		"readTime",
+		"transaction",
+	}
+
+	// Keys, that might appear in the 'listDocuments' response page.
+	listDocumentsKeys = []string {
+		"documents",
+		"nextPageToken",
+	}
+)
+
+// Checks, whether all keys of the map provided belong to the list of the allowed ones.
+func hasOnlyKeys(payloadMap map[string]interface{}, allowedKeys []string) bool {
+	for k := range payloadMap {
+		if !slices.Contains(allowedKeys, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks, whether the payload is a 'runQuery' response - [{"document": {...}, "readTime": "..."}, ...].
+func isRunQueryResponse(payload interface{}) bool {
+	payloadArr, ok := payload.([]interface{})
+	if !ok || len(payloadArr) == 0 {
+		return false
+	}
+
+	for _, elem := range payloadArr {
+		elemMap, ok := elem.(map[string]interface{})
+		if !ok || !hasOnlyKeys(elemMap, runQueryKeys) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks, whether the payload is a 'batchGet' response - [{"found": {...}, "readTime": "..."}, {"missing": "projects/..."}].
+func isBatchGetResponse(payload interface{}) bool {
+	payloadArr, ok := payload.([]interface{})
+	if !ok || len(payloadArr) == 0 {
+		return false
+	}
+
+	for _, elem := range payloadArr {
+		elemMap, ok := elem.(map[string]interface{})
+		if !ok || !hasOnlyKeys(elemMap, batchGetKeys) {
+			return false
+		}
+		_, found := elemMap["found"]
+		_, missing := elemMap["missing"]
+		if found == missing {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks, whether the payload is a 'listDocuments' response page - {"documents": [...], "nextPageToken": "..."}.
+// API omits 'documents' of the empty pages (e.g. the last one), so {} and {"nextPageToken": "..."} are the pages as well.
+func isListDocumentsResponse(payload interface{}) bool {
+	payloadMap, ok := payload.(map[string]interface{})
+	if !ok || !hasOnlyKeys(payloadMap, listDocumentsKeys) {
+		return false
+	}
+
+	rawDocuments, documentsFound := payloadMap["documents"]
+	if !documentsFound {
+		return true
+	}
+	documents, ok := rawDocuments.([]interface{})
+	if !ok {
+		return false
+	}
+
+	// Plain JSON might have the 'documents' key as well, so the documents should look like the Firestore ones.
+	for _, doc := range documents {
+		docMap, ok := doc.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, nameFound := docMap[documentNameKey]; !nameFound {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks, whether the payload is one of the Firestore API responses, that contain multiple documents.
+func isDocumentsResponse(payload interface{}) bool {
+	return isBatchGetResponse(payload) || isRunQueryResponse(payload) || isListDocumentsResponse(payload)
+}
+
+// Extracts the documents and the names of the missing documents from the Firestore API response.
+func extractResponseDocuments(payload interface{}) ([]interface{}, []string, error) {
+	documents := []interface{}{}
+	missing := []string{}
+
+	switch {
+	case isBatchGetResponse(payload):
+		for i, elem := range payload.([]interface{}) {
+			elemMap := elem.(map[string]interface{})
+			if doc, found := elemMap["found"]; found {
+				documents = append(documents, doc)
+				continue
+			}
+			name, ok := elemMap["missing"].(string)
+			if !ok {
+				return nil, nil, newError(ErrInvalidStructure, fmt.Sprintf("[%d]/missing", i), "", "'missing' document name is not a string")
+			}
+			missing = append(missing, name)
+		}
+	case isRunQueryResponse(payload):
+		// Elements without a document only report 'readTime' or 'skippedResults', so they are skipped.
+		for _, elem := range payload.([]interface{}) {
+			if doc, found := elem.(map[string]interface{})["document"]; found {
+				documents = append(documents, doc)
+			}
+		}
+	case isListDocumentsResponse(payload):
+		pageDocuments, _ := payload.(map[string]interface{})["documents"].([]interface{})
+		documents = append(documents, pageDocuments...)
+	default:
+		return nil, nil, newError(ErrInvalidPayload, "", "", "Payload provided is not a 'runQuery', 'batchGet' or 'listDocuments' response.")
+	}
+
+	return documents, missing, nil
+}
+
+// Decodes the documents provided and puts them into a collection - either a map keyed by the document ID, or an array.
+func decodeDocuments(documents []interface{}, opts *Options) (interface{}, error) {
+	resMap := map[string]interface{}{}
+	resArr := []interface{}{}
+
+	for i, doc := range documents {
+		docMap, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, newError(ErrInvalidStructure, fmt.Sprintf("[%d]", i), "", "Document is not an object")
+		}
+
+		decodedDoc, err := DecodeFromFirestoreWithOptions(docMap, *opts)
+		if err != nil {
+			return nil, fmt.Errorf("Document under the index %d can't be decoded. Reason - %w", i, err)
+		}
+
+		if opts.CollectionFormat == CollectionFormatArray {
+			resArr = append(resArr, decodedDoc)
+			continue
+		}
+
+		name, ok := docMap[documentNameKey].(string)
+		if !ok {
+			return nil, newError(ErrInvalidStructure, fmt.Sprintf("[%d]", i), "", "Document does not have a name, so it can't be keyed by its ID")
+		}
+		id := documentID(name)
+		if _, found := resMap[id]; found {
+			return nil, newError(ErrInvalidPayload, "", "", fmt.Sprintf(
+				"Documents contain the duplicate ID -> %s (e.g. from different parents or overlapping pages). Use the 'array' collection format instead",
+				id,
+			))
+		}
+		resMap[id] = decodedDoc
+	}
+
+	if opts.CollectionFormat == CollectionFormatArray {
+		return resArr, nil
+	}
+	return resMap, nil
+}
+
+// Decodes the documents of one or multiple Firestore API responses (e.g. paginated 'listDocuments' responses)
+// into a single collection. Names of the documents, reported as missing by the 'batchGet', are returned separately.
+func DecodeResponses(payloads []interface{}, opts Options) (interface{}, []string, error) {
+	documents := []interface{}{}
+	missing := []string{}
+
+	for i, payload := range payloads {
+		responseDocs, responseMissing, err := extractResponseDocuments(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Response under the index %d is invalid. Reason - %w", i, err)
+		}
+		documents = append(documents, responseDocs...)
+		missing = append(missing, responseMissing...)
+	}
+
+	decodedDocs, err := decodeDocuments(documents, &opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return decodedDocs, missing, nil
+}
+
+// Decodes the documents of the 'runQuery' response stream.
+func DecodeRunQueryResponse(payload []interface{}, opts Options) (interface{}, error) {
+	if !isRunQueryResponse(payload) {
+		return nil, newError(ErrInvalidPayload, "", "", "Payload provided is not a 'runQuery' response.")
+	}
+
+	decodedDocs, _, err := DecodeResponses([]interface{}{payload}, opts)
+	return decodedDocs, err
+}
+
+// Decodes the documents of the 'batchGet' response stream. Names of the missing documents are returned separately.
+func DecodeBatchGetResponse(payload []interface{}, opts Options) (interface{}, []string, error) {
+	if !isBatchGetResponse(payload) {
+		return nil, nil, newError(ErrInvalidPayload, "", "", "Payload provided is not a 'batchGet' response.")
+	}
+
+	return DecodeResponses([]interface{}{payload}, opts)
+}
+
+// Decodes the documents of the 'listDocuments' response pages into a single collection.
+func DecodeListDocumentsResponses(payloads []map[string]interface{}, opts Options) (interface{}, error) {
+	responses := make([]interface{}, 0, len(payloads))
+	for i, payload := range payloads {
+		if !isListDocumentsResponse(payload) {
+			return nil, newError(ErrInvalidPayload, "", "", fmt.Sprintf("Payload under the index %d is not a 'listDocuments' response.", i))
+		}
+		responses = append(responses, payload)
+	}
+
+	decodedDocs, _, err := DecodeResponses(responses, opts)
+	return decodedDocs, err
+}
+
+// Returns the amount of the documents in the decoded collection.
+func collectionSize(collection interface{}) int {
+	switch t := collection.(type) {
+	case map[string]interface{}:
+		return len(t)
+	case []interface{}:
+		return len(t)
+	}
+	return 0
+}
+
+// Reports the names of the documents, that were requested, but not found.
+func reportMissingDocuments(missing []string) {
+	if len(missing) == 0 {
+		return
+	}
+	slog.Warn(
+		fmt.Sprintf(
+			"The following %d requested document(s) were not found: %s",
+			len(missing), strings.Join(missing, ", "),
+		),
+	)
+}
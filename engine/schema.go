@@ -0,0 +1,382 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// Segment of the schema field path, that matches any key of the map.
+	schemaWildcard = "*"
+	// Segment of the schema field path, that matches any element of the array.
+	schemaArrayElem = "[]"
+	// Schema type, that falls back to the guessing of the Firestore type from the plain JSON value.
+	schemaAnyType = "any"
+)
+
+var (
+	// Schema types and their respective Firestore type keys.
+	schemaTypes = map[string]string {
+		"null": "nullValue",
+		"boolean": "booleanValue",
+		"integer": "integerValue",
+		"double": "doubleValue",
+		"timestamp": "timestampValue",
+		"string": "stringValue",
+		"bytes": "bytesValue",
+		"reference": "referenceValue",
+		"geoPoint": "geoPointValue",
+		"vector": "vectorValue",
+		"array": "arrayValue",
+		"map": "mapValue",
+		schemaAnyType: "",
+	}
+)
+
+type schemaRule struct {
+	path string
+	segments []string
+	fieldType string
+}
+
+// Returns the amount of wildcard segments, so the most specific rule could be picked.
+func (sr *schemaRule) wildcards() int {
+	count := 0
+	for _, segment := range sr.segments {
+		if segment == schemaWildcard {
+			count++
+		}
+	}
+	return count
+}
+
+func (sr *schemaRule) matches(segments []string, prefixOnly bool) bool {
+	if (!prefixOnly && len(sr.segments) != len(segments)) || len(sr.segments) < len(segments) {
+		return false
+	}
+	for i, segment := range segments {
+		ruleSegment := sr.segments[i]
+		if ruleSegment == segment || (ruleSegment == schemaWildcard && segment != schemaArrayElem) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Schema maps field paths to the Firestore types, the values under these paths should be encoded into.
+//
+// Field paths consist of the dot separated map keys - 'profile.address.city'. Array elements are referenced by the
+// '[]' suffix - 'tags[]', any key of the map - by the '*' wildcard - 'labels.*'. Keys, that contain special characters,
+// should be wrapped into backticks - 'profile.`first.name`'. Maps, that are parents of the declared paths, don't have
+// to be declared explicitly.
+type Schema struct {
+	rules []schemaRule
+}
+
+// Returns the most specific rule, declared for the field path provided.
+func (s *Schema) lookup(segments []string) *schemaRule {
+	var found *schemaRule
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !rule.matches(segments, false) {
+			continue
+		}
+		if found == nil || rule.wildcards() < found.wildcards() {
+			found = rule
+		}
+	}
+	return found
+}
+
+// Checks, whether there are rules, declared for the children of the field path provided.
+func (s *Schema) hasChildren(segments []string) bool {
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if len(rule.segments) > len(segments) && rule.matches(segments, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the declared field paths and their types.
+func (s *Schema) Fields() map[string]string {
+	fields := make(map[string]string, len(s.rules))
+	for _, rule := range s.rules {
+		fields[rule.path] = rule.fieldType
+	}
+	return fields
+}
+
+// Formats the map key as a schema field path segment. Literal '*' key is quoted, so it's not taken for a wildcard.
+func formatSchemaSegment(key string) string {
+	if key == schemaWildcard {
+		return "`" + key + "`"
+	}
+	return quoteFieldPathSegment(key)
+}
+
+// Splits the schema field path into segments. Array elements are represented by the '[]' segment.
+func parseSchemaPath(path string) ([]string, error) {
+	segments := []string{}
+	i := 0
+	for i < len(path) {
+		var segment strings.Builder
+		if path[i] == '`' {
+			i++
+			closed := false
+			for i < len(path) {
+				if path[i] == '\\' && i + 1 < len(path) {
+					segment.WriteByte(path[i + 1])
+					i += 2
+					continue
+				}
+				if path[i] == '`' {
+					closed = true
+					i++
+					break
+				}
+				segment.WriteByte(path[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("field path -> %s contains an unclosed backtick", path)
+			}
+		} else {
+			for i < len(path) && path[i] != '.' && path[i] != '[' {
+				segment.WriteByte(path[i])
+				i++
+			}
+		}
+
+		if segment.Len() == 0 {
+			return nil, fmt.Errorf("field path -> %s contains an empty segment", path)
+		}
+		segments = append(segments, segment.String())
+
+		for strings.HasPrefix(path[i:], schemaArrayElem) {
+			segments = append(segments, schemaArrayElem)
+			i += len(schemaArrayElem)
+		}
+
+		if i < len(path) {
+			if path[i] != '.' || i == len(path) - 1 {
+				return nil, fmt.Errorf("field path -> %s contains an unexpected character at the position %d", path, i)
+			}
+			i++
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, errors.New("field path can't be empty")
+	}
+
+	return segments, nil
+}
+
+func NewSchema(fields map[string]string) (*Schema, error) {
+	schema := &Schema{rules: []schemaRule{}}
+
+	// Keys are sorted, so the validation errors are reported in the same order each time.
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fieldType := fields[path]
+		if _, ok := schemaTypes[fieldType]; !ok {
+			return nil, newError(ErrInvalidUsage, "", "", fmt.Sprintf("Schema field path -> %s has an unsupported type -> %s", path, fieldType))
+		}
+		segments, err := parseSchemaPath(path)
+		if err != nil {
+			return nil, &Error{Kind: ErrInvalidUsage, Reason: fmt.Sprintf("Schema field path -> %s is invalid. Reason - %s", path, err.Error()), Err: err}
+		}
+		schema.rules = append(schema.rules, schemaRule{path: path, segments: segments, fieldType: fieldType})
+	}
+
+	return schema, nil
+}
+
+// Reads the schema from the file, that has the following structure - {"fields": {"profile.age": "integer"}}.
+func LoadSchema(path string) (*Schema, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newFileError(ErrIO, path, "There was an issue with reading the schema file. Err - " + err.Error(), err)
+	}
+
+	schemaFile := struct {
+		Fields map[string]string `json:"fields"`
+	}{}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decodeJSON(decoder, &schemaFile); err != nil {
+		return nil, newFileError(ErrInvalidUsage, path, "Schema file contains an invalid json structure. Err - " + err.Error(), err)
+	}
+
+	if len(schemaFile.Fields) == 0 {
+		return nil, newFileError(ErrInvalidUsage, path, "Schema file does not declare any fields", nil)
+	}
+
+	return NewSchema(schemaFile.Fields)
+}
+
+// Encodes the value strictly into the Firestore type declared in the schema.
+func handleSchemaType(payloadVal interface{}, segments []string, path string, schema *Schema, opts *Options) (interface{}, error) {
+	rule := schema.lookup(segments)
+
+	if rule == nil {
+		// Parent maps and arrays of the declared paths are not required to be declared.
+		if schema.hasChildren(segments) {
+			switch t := payloadVal.(type) {
+			case map[string]interface{}:
+				return handleSchemaMap(t, segments, path, schema, opts)
+			case []interface{}:
+				return handleSchemaArray(t, segments, path, schema, opts)
+			}
+		}
+		return nil, newError(ErrSchemaMismatch, path, "", "Its field path is not declared in the schema.")
+	}
+
+	if rule.fieldType == schemaAnyType {
+		return handleGoType(payloadVal, path, opts)
+	}
+
+	typeKey := schemaTypes[rule.fieldType]
+	mismatchErr := newError(
+		ErrSchemaMismatch, path, typeKey,
+		fmt.Sprintf("Value does not match the '%s' type declared in the schema under the path -> %s.", rule.fieldType, rule.path),
+	)
+
+	// Tagged values are accepted, as long as they are encoded into the declared type.
+	if mapVal, isMap := payloadVal.(map[string]interface{}); isMap && rule.fieldType != "map" {
+		taggedVal, isTagged, err := handleGoTaggedMap(mapVal, path, opts)
+		if err != nil {
+			return nil, err
+		}
+		if !isTagged || encodedTypeKey(taggedVal) != typeKey {
+			return nil, mismatchErr
+		}
+		return taggedVal, nil
+	}
+
+	switch rule.fieldType {
+	case "null":
+		if payloadVal == nil {
+			return handleGoSingularType(nil, typeKey), nil
+		}
+	case "boolean":
+		if boolVal, ok := payloadVal.(bool); ok {
+			return handleGoSingularType(boolVal, typeKey), nil
+		}
+	case "integer":
+		if _, isStr := payloadVal.(string); !isStr {
+			if intNum, err := handleGoInteger(payloadVal); err == nil {
+				return handleGoSingularType(intNum, typeKey), nil
+			}
+		}
+	case "double":
+		if floatNum, ok := toFloat64(payloadVal); ok {
+			return handleGoSingularType(floatNum, typeKey), nil
+		}
+	case "timestamp":
+		if timestampVal, err := handleTimestampValue(payloadVal); err == nil {
+			return handleGoSingularType(timestampVal, typeKey), nil
+		}
+	case "string":
+		if strVal, ok := payloadVal.(string); ok {
+			return handleGoSingularType(strVal, typeKey), nil
+		}
+	case "bytes":
+		if bytesVal, err := handleExplicitByteValue(payloadVal); err == nil {
+			return handleGoSingularType(bytesVal, typeKey), nil
+		}
+	case "reference":
+		if _, isStr := payloadVal.(string); isStr {
+			ref, err := handleReferenceValue(payloadVal)
+			if err != nil {
+				return nil, wrapError(ErrInvalidValue, path, typeKey, err)
+			}
+			return handleGoSingularType(ref, typeKey), nil
+		}
+	case "vector":
+		if _, isArr := payloadVal.([]interface{}); isArr {
+			vector, err := handleGoVector(payloadVal)
+			if err != nil {
+				return nil, wrapError(ErrInvalidValue, path, typeKey, err)
+			}
+			return vector, nil
+		}
+	case "array":
+		if arrVal, ok := payloadVal.([]interface{}); ok {
+			return handleSchemaArray(arrVal, segments, path, schema, opts)
+		}
+	case "map":
+		if mapVal, ok := payloadVal.(map[string]interface{}); ok {
+			return handleSchemaMap(mapVal, segments, path, schema, opts)
+		}
+	}
+
+	return nil, mismatchErr
+}
+
+func handleSchemaMap(mapVal map[string]interface{}, segments []string, path string, schema *Schema, opts *Options) (interface{}, error) {
+	fields := make(map[string]interface{}, len(mapVal))
+	for k, v := range mapVal {
+		childSegments := append(slices.Clone(segments), k)
+		processedVal, err := handleSchemaType(v, childSegments, path + "/" + k, schema, opts)
+		if err != nil {
+			if opts.collectError(err) {
+				continue
+			}
+			return nil, err
+		}
+		fields[k] = processedVal
+	}
+	return map[string]interface{}{"mapValue": map[string]interface{}{"fields": fields}}, nil
+}
+
+func handleSchemaArray(arrVal []interface{}, segments []string, path string, schema *Schema, opts *Options) (interface{}, error) {
+	values := make([]interface{}, 0, len(arrVal))
+	childSegments := append(slices.Clone(segments), schemaArrayElem)
+	for i, elem := range arrVal {
+		processedElem, err := handleSchemaType(elem, childSegments, path + fmt.Sprintf("[%d]", i), schema, opts)
+		if err != nil {
+			if opts.collectError(err) {
+				continue
+			}
+			return nil, err
+		}
+		values = append(values, processedElem)
+	}
+	return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}, nil
+}
+
+// Returns the Firestore type key of the encoded value. Vectors are reported as 'vectorValue'.
+func encodedTypeKey(encodedVal interface{}) string {
+	encodedMap, ok := encodedVal.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for k, v := range encodedMap {
+		if k == "mapValue" && isFirestoreVector(v) {
+			return "vectorValue"
+		}
+		return k
+	}
+	return ""
+}
+
+func EncodeToFirestoreWithSchema(payload map[string]interface{}, schema *Schema, opts Options) (map[string]interface{}, error) {
+	opts.Schema = schema
+	return EncodeToFirestoreWithOptions(payload, opts)
+}
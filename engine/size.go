@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Defines, what happens, when the estimated size of the encoded document exceeds the limit.
+type OversizeMode string
+
+const (
+	// Warning is logged, but the output is still written.
+	OversizeWarn OversizeMode = "warn"
+	// Output of the file is not written.
+	OversizeFail OversizeMode = "fail"
+)
+
+const (
+	// Maximum size of the Firestore document - 1 MiB.
+	defaultMaxDocumentSize = 1024 * 1024
+	// Additional bytes, every document takes.
+	documentOverhead = 32
+	// Additional bytes, every document name takes.
+	documentNameOverhead = 16
+	// Amount of the biggest field paths, listed when the document exceeds the limit.
+	reportedFieldPaths = 5
+)
+
+// Estimated storage size of the single field path - size of its name and its value.
+type FieldSize struct {
+	Path string `json:"path"`
+	Bytes int `json:"bytes"`
+}
+
+// Estimated storage size of the encoded document, calculated according to the Firestore sizing rules.
+type DocumentSize struct {
+	// Name of the document. Empty, if the document has no name, in which case its size is not counted.
+	Name string `json:"name,omitempty"`
+	Bytes int `json:"bytes"`
+	// Sizes of all the field paths of the document (including the nested ones), the biggest go first.
+	Fields []FieldSize `json:"fields"`
+}
+
+// Returns the field paths, that contribute the most to the size of the document.
+func (ds *DocumentSize) Largest(n int) []FieldSize {
+	return ds.Fields[:min(n, len(ds.Fields))]
+}
+
+func (ds *DocumentSize) displayName() string {
+	if ds.Name == "" {
+		return "(unnamed)"
+	}
+	return ds.Name
+}
+
+// Checks the size of the document against the limit provided.
+func (ds *DocumentSize) exceeds(maxSize int) error {
+	if ds.Bytes <= maxSize {
+		return nil
+	}
+
+	largest := []string{}
+	for _, field := range ds.Largest(reportedFieldPaths) {
+		largest = append(largest, fmt.Sprintf("%s (%d bytes)", field.Path, field.Bytes))
+	}
+
+	return newError(ErrDocumentTooLarge, "", "", fmt.Sprintf(
+		"Estimated size of the document -> %s is %d bytes, which exceeds the limit of %d bytes. Biggest field paths: %s",
+		ds.displayName(), ds.Bytes, maxSize, strings.Join(largest, ", "),
+	))
+}
+
+func stringSize(str string) int {
+	return len(str) + 1
+}
+
+// Size of the document name is the sum of its collection and document IDs plus 16 bytes.
+func documentNameSize(name string) int {
+	_, relativePath, found := strings.Cut(name, "/documents/")
+	if !found {
+		return 0
+	}
+	size := documentNameOverhead
+	for _, segment := range strings.Split(relativePath, "/") {
+		size += stringSize(segment)
+	}
+	return size
+}
+
+// Returns the size of the encoded Firestore value. Sizes of the nested field paths are appended to the fields provided.
+func encodedValueSize(encodedVal interface{}, fieldPath string, fields *[]FieldSize) int {
+	encodedMap, _ := encodedVal.(map[string]interface{})
+
+	for typeKey, v := range encodedMap {
+		switch typeKey {
+		case "nullValue", "booleanValue":
+			return 1
+		case "integerValue", "doubleValue", "timestampValue":
+			return 8
+		case "geoPointValue":
+			return 16
+		case "stringValue":
+			strVal, _ := v.(string)
+			return stringSize(strVal)
+		case "bytesValue":
+			strVal, _ := v.(string)
+			if decoded, err := base64.StdEncoding.DecodeString(strVal); err == nil {
+				return len(decoded)
+			}
+			return len(strVal)
+		case "referenceValue":
+			strVal, _ := v.(string)
+			return documentNameSize(strVal)
+		case "arrayValue":
+			values, _ := v.(map[string]interface{})["values"].([]interface{})
+			size := 0
+			for i, elem := range values {
+				size += encodedValueSize(elem, fieldPath + fmt.Sprintf("[%d]", i), fields)
+			}
+			return size
+		case "mapValue":
+			mapFields, _ := v.(map[string]interface{})["fields"].(map[string]interface{})
+			return encodedFieldsSize(mapFields, fieldPath, fields)
+		}
+	}
+
+	return 0
+}
+
+// Returns the size of the encoded map fields - sizes of their names and values.
+func encodedFieldsSize(encodedFields map[string]interface{}, parentPath string, fields *[]FieldSize) int {
+	size := 0
+	for k, v := range encodedFields {
+		fieldPath := quoteFieldPathSegment(k)
+		if parentPath != "" {
+			fieldPath = parentPath + "." + fieldPath
+		}
+		fieldSize := stringSize(k) + encodedValueSize(v, fieldPath, fields)
+		*fields = append(*fields, FieldSize{Path: fieldPath, Bytes: fieldSize})
+		size += fieldSize
+	}
+	return size
+}
+
+// Estimates the storage size of the encoded document ({"name": ..., "fields": {...}}).
+func EstimateDocumentSize(encodedDoc map[string]interface{}) *DocumentSize {
+	name, _ := encodedDoc[documentNameKey].(string)
+	encodedFields, _ := encodedDoc["fields"].(map[string]interface{})
+
+	ds := &DocumentSize{Name: name, Fields: []FieldSize{}}
+	ds.Bytes = documentNameSize(name) + encodedFieldsSize(encodedFields, "", &ds.Fields) + documentOverhead
+
+	sort.SliceStable(ds.Fields, func(i, j int) bool {
+		if ds.Fields[i].Bytes != ds.Fields[j].Bytes {
+			return ds.Fields[i].Bytes > ds.Fields[j].Bytes
+		}
+		return ds.Fields[i].Path < ds.Fields[j].Path
+	})
+
+	return ds
+}
+
+// Estimates the sizes of the documents, written by the 'commit' or 'batchWrite' request bodies.
+func estimateWriteBodySizes(writeBodies []interface{}) []*DocumentSize {
+	sizes := []*DocumentSize{}
+	for _, body := range writeBodies {
+		writes, _ := body.(map[string]interface{})["writes"].([]interface{})
+		for _, write := range writes {
+			if update, ok := write.(map[string]interface{})["update"].(map[string]interface{}); ok {
+				sizes = append(sizes, EstimateDocumentSize(update))
+			}
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	// Sentinel, that sets the field to the time the write was processed by the server.
+	serverTimestampSentinel = "$serverTimestamp"
+)
+
+var (
+	// Field transform sentinels of the plain JSON - {"$increment": 5} - and their respective Firestore transforms.
+	transformTags = map[string]string {
+		"$increment": "increment",
+		"$maximum": "maximum",
+		"$minimum": "minimum",
+		"$arrayUnion": "appendMissingElements",
+		"$arrayRemove": "removeAllFromArray",
+	}
+)
+
+// Returns the transform tag of the map, in case, if the map contains one.
+func findTransformTag(mapVal map[string]interface{}) (string, bool) {
+	for k := range mapVal {
+		if _, isTransform := transformTags[k]; isTransform {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// Checks, whether the value contains any transform sentinels.
+func containsTransforms(payloadVal interface{}) bool {
+	switch t := payloadVal.(type) {
+	case string:
+		return t == serverTimestampSentinel
+	case []interface{}:
+		return slices.ContainsFunc(t, containsTransforms)
+	case map[string]interface{}:
+		if _, isTransform := findTransformTag(t); isTransform {
+			return true
+		}
+		if isTaggedShape(t) {
+			return false
+		}
+		for _, v := range t {
+			if containsTransforms(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Builds the Firestore field transform from the transform sentinel.
+func buildFieldTransform(tag string, tagVal interface{}, segments []string, opts *Options) (map[string]interface{}, error) {
+	fieldPath := formatFieldPath(segments)
+	transform := map[string]interface{}{"fieldPath": fieldPath}
+	transformKey := transformTags[tag]
+	path := fieldPath + "/" + tag
+
+	switch tag {
+	case "$increment", "$maximum", "$minimum":
+		encodedVal, err := handleGoType(tagVal, path, opts)
+		if err != nil {
+			return nil, err
+		}
+		if typeKey := encodedTypeKey(encodedVal); typeKey != "integerValue" && typeKey != "doubleValue" {
+			return nil, newError(ErrInvalidTransform, path, transformKey, "Transform value should be a number.")
+		}
+		transform[transformKey] = encodedVal
+	case "$arrayUnion", "$arrayRemove":
+		if _, isArr := tagVal.([]interface{}); !isArr || containsTransforms(tagVal) {
+			return nil, newError(ErrInvalidTransform, path, transformKey, "Transform value should be an array without transforms.")
+		}
+		// Elements are written into the field, so they are subject to the same constraints, as the regular values.
+		violations := []constraintViolation{}
+		collectViolations(tagVal, path, len(segments), &violations)
+		if err := joinViolations(violations); err != nil {
+			return nil, err
+		}
+		encodedVal, err := handleGoArray(tagVal, path, opts)
+		if err != nil {
+			return nil, err
+		}
+		transform[transformKey] = encodedVal.(map[string]interface{})["arrayValue"]
+	}
+
+	return transform, nil
+}
+
+// Strips the transform sentinels from the plain payload. Returns the remaining fields and the Firestore field
+// transforms, sorted by their field paths. Maps, that contained only transforms, are stripped as well.
+func extractTransforms(payload map[string]interface{}, segments []string, opts *Options) (map[string]interface{}, []interface{}, error) {
+	fields := make(map[string]interface{}, len(payload))
+	transforms := []interface{}{}
+
+	for k, v := range payload {
+		fieldSegments := append(slices.Clone(segments), k)
+		fieldPath := formatFieldPath(fieldSegments)
+
+		if v == serverTimestampSentinel {
+			transforms = append(transforms, map[string]interface{}{"fieldPath": fieldPath, "setToServerValue": "REQUEST_TIME"})
+			continue
+		}
+
+		switch t := v.(type) {
+		case []interface{}:
+			if containsTransforms(t) {
+				return nil, nil, newError(ErrInvalidTransform, fieldPath, "", "Array contains a field transform. Transforms can't be applied to the array elements")
+			}
+		case map[string]interface{}:
+			if tag, isTransform := findTransformTag(t); isTransform {
+				if len(t) != 1 {
+					return nil, nil, newError(ErrInvalidTransform, fieldPath, "", fmt.Sprintf("Field path has both a value and a '%s' transform", tag))
+				}
+				transform, err := buildFieldTransform(tag, t[tag], fieldSegments, opts)
+				if err != nil {
+					return nil, nil, err
+				}
+				transforms = append(transforms, transform)
+				continue
+			}
+			if !isTaggedShape(t) && containsTransforms(t) {
+				nestedFields, nestedTransforms, err := extractTransforms(t, fieldSegments, opts)
+				if err != nil {
+					return nil, nil, err
+				}
+				transforms = append(transforms, nestedTransforms...)
+				if len(nestedFields) > 0 {
+					fields[k] = nestedFields
+				}
+				continue
+			}
+		}
+
+		fields[k] = v
+	}
+
+	sort.Slice(transforms, func(i, j int) bool {
+		return transforms[i].(map[string]interface{})["fieldPath"].(string) < transforms[j].(map[string]interface{})["fieldPath"].(string)
+	})
+
+	return fields, transforms, nil
+}
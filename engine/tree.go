@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"github.com/mvksxm/firestore-json-convert/utils"
+)
+
+const (
+	// Pattern of the files, that are picked from the input directories and glob patterns by default.
+	defaultIncludePattern = "*.json"
+)
+
+// Input files, found in the directories and by the glob patterns, and their respective output files.
+type inputTree struct {
+	inputPaths []string
+	outputPaths []string
+	// Amount of the files, that were filtered out by the include and exclude patterns.
+	filtered int
+}
+
+// Returns the directory, the glob pattern is resolved relative to - the part before the first segment with a wildcard.
+func globBase(pattern string) string {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	for i, segment := range segments {
+		if utils.IsGlobPattern(segment) {
+			if i == 0 {
+				return "."
+			}
+			if i == 1 && segments[0] == "" {
+				return "/"
+			}
+			return filepath.FromSlash(strings.Join(segments[:i], "/"))
+		}
+	}
+	return filepath.Dir(pattern)
+}
+
+// Checks, whether the relative path matches any of the patterns. Patterns with a slash are matched against the
+// whole relative path ('users/*.json'), the rest - against the file name ('*_test.json').
+func matchesAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		target := filepath.Base(relPath)
+		if strings.Contains(pattern, "/") {
+			target = filepath.ToSlash(relPath)
+		}
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *inputTree) add(inputPath string, base string, outputDir string) {
+	relPath, err := filepath.Rel(base, inputPath)
+	if err != nil {
+		relPath = filepath.Base(inputPath)
+	}
+	it.inputPaths = append(it.inputPaths, inputPath)
+	it.outputPaths = append(it.outputPaths, filepath.Join(outputDir, relPath))
+}
+
+// Expands the directories and the glob patterns provided into the input files and the output files, which mirror
+// the input tree under the output directory. Include and exclude patterns are applied to the files, found in the
+// directories and by the glob patterns, while the files, provided explicitly, are always converted.
+func expandInputTree(inputs []string, outputDir string, include []string, exclude []string) (*inputTree, error) {
+	if outputDir == "" || utils.IsStdStream(outputDir) {
+		return nil, newError(ErrInvalidUsage, "", "", "In case, if directories or glob patterns are provided, output directory (-o CLI flag) should be specified!")
+	}
+	if pathInfo, err := os.Stat(outputDir); err == nil && !pathInfo.IsDir() {
+		return nil, newFileError(ErrInvalidUsage, outputDir, "Output path should be a directory, since directories or glob patterns are provided.", nil)
+	}
+
+	if len(include) == 0 {
+		include = []string{defaultIncludePattern}
+	}
+	for _, pattern := range slices.Concat(include, exclude) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, &Error{Kind: ErrInvalidUsage, Reason: fmt.Sprintf("Include/exclude pattern -> %s is invalid. Err - %s", pattern, err.Error()), Err: err}
+		}
+	}
+
+	it := &inputTree{inputPaths: []string{}, outputPaths: []string{}}
+	seen := map[string]bool{}
+	absOutputDir, _ := filepath.Abs(outputDir)
+
+	addFiltered := func(inputPath string, base string) {
+		if seen[inputPath] {
+			return
+		}
+		seen[inputPath] = true
+
+		relPath, _ := filepath.Rel(base, inputPath)
+		if !matchesAny(include, relPath) || matchesAny(exclude, relPath) {
+			it.filtered++
+			return
+		}
+		it.add(inputPath, base, outputDir)
+	}
+
+	walk := func(dir string, base string) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				// Outputs of the previous runs are not picked up, in case, if the output directory is inside the input one.
+				if absPath, _ := filepath.Abs(path); absPath == absOutputDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			addFiltered(path, base)
+			return nil
+		})
+	}
+
+	for _, input := range inputs {
+		switch {
+		case utils.IsStdStream(input):
+			return nil, newError(ErrInvalidUsage, "", "", "Standard input ('-') can't be combined with the directories or glob patterns.")
+		case utils.IsDir(input):
+			if err := walk(input, input); err != nil {
+				return nil, newFileError(ErrIO, input, "There was an issue with reading the input directory. Err - " + err.Error(), err)
+			}
+		case utils.IsGlobPattern(input) && !utils.IsFile(input):
+			matches, err := filepath.Glob(input)
+			if err != nil {
+				return nil, &Error{Kind: ErrInvalidUsage, Reason: fmt.Sprintf("Glob pattern -> %s is invalid. Err - %s", input, err.Error()), Err: err}
+			}
+			if len(matches) == 0 {
+				slog.Warn(fmt.Sprintf("Glob pattern -> %s does not match any files.", input))
+			}
+			base := globBase(input)
+			for _, match := range matches {
+				if utils.IsDir(match) {
+					if err := walk(match, base); err != nil {
+						return nil, newFileError(ErrIO, match, "There was an issue with reading the input directory. Err - " + err.Error(), err)
+					}
+					continue
+				}
+				addFiltered(match, base)
+			}
+		default:
+			if !seen[input] {
+				seen[input] = true
+				it.add(input, filepath.Dir(input), outputDir)
+			}
+		}
+	}
+
+	if len(it.inputPaths) == 0 {
+		return nil, newError(ErrInvalidUsage, "", "", "None of the directories or glob patterns provided contain files, that could be converted! Exiting...")
+	}
+
+	// Parent directories of the output files should exist, so the output paths are validated successfully.
+	for _, outputPath := range it.outputPaths {
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0777); err != nil {
+			return nil, newFileError(ErrIO, filepath.Dir(outputPath), "There was an issue with creating the output directory. Err - " + err.Error(), err)
+		}
+	}
+
+	return it, nil
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Defines, which Firestore API request body is generated from the plain JSON collection.
+type WriteBodyMode string
+
+const (
+	// Regular document - {"fields": {...}}.
+	WriteBodyNone WriteBodyMode = ""
+	// 'documents:commit' request body - {"writes": [...]}.
+	WriteBodyCommit WriteBodyMode = "commit"
+	// 'documents:batchWrite' request body - {"writes": [...]}.
+	WriteBodyBatchWrite WriteBodyMode = "batchWrite"
+)
+
+// Defines the 'currentDocument' precondition of the generated writes.
+type PreconditionMode string
+
+const (
+	PreconditionNone PreconditionMode = "none"
+	// Document should exist, so the write only updates it.
+	PreconditionExists PreconditionMode = "exists"
+	// Document should not exist, so the write only creates it.
+	PreconditionMissing PreconditionMode = "missing"
+)
+
+// Defines, whether the generated writes carry the 'updateMask'.
+type UpdateMaskMode string
+
+const (
+	// Every write masks the fields of its document, so the fields, which are not provided, are kept.
+	UpdateMaskFields UpdateMaskMode = "fields"
+	// Writes are not masked, so the whole document is replaced (before its transforms are applied).
+	UpdateMaskNone UpdateMaskMode = "none"
+)
+
+const (
+	// Maximum amount of writes, that could be sent in a single 'commit' or 'batchWrite' request.
+	maxWritesPerRequest = 500
+)
+
+// Returns the field paths of the literal fields of the document. Maps, that contain transforms, are not masked
+// as a whole, so their fields, which are not provided, are kept.
+func updateMaskPaths(payload map[string]interface{}, fields map[string]interface{}, segments []string) []string {
+	paths := []string{}
+	for k, v := range fields {
+		fieldSegments := append(slices.Clone(segments), k)
+		nestedPayload, isMap := payload[k].(map[string]interface{})
+		nestedFields, isNestedMap := v.(map[string]interface{})
+		if isMap && isNestedMap && !isTaggedShape(nestedPayload) && containsTransforms(nestedPayload) {
+			paths = append(paths, updateMaskPaths(nestedPayload, nestedFields, fieldSegments)...)
+			continue
+		}
+		paths = append(paths, formatFieldPath(fieldSegments))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Builds the 'update' write of the document, which name is built from the collection path and the ID provided.
+func buildUpdateWrite(id string, doc interface{}, collectionPath string, opts *Options) (map[string]interface{}, error) {
+	if !isValidDocumentID(id) {
+		return nil, newError(ErrInvalidValue, id, "", "Document ID should be a non-empty string without slashes")
+	}
+
+	docMap, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, newError(ErrInvalidPayload, id, "", "Document is not a json object")
+	}
+
+	// Transforms are not the part of the document, so they are applied after the document is written.
+	fields, transforms, err := extractTransforms(docMap, []string{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("Document with the ID -> %s contains invalid transforms. Reason - %w", id, err)
+	}
+
+	encodedDoc, err := EncodeToFirestoreWithOptions(fields, *opts)
+	if err != nil {
+		return nil, fmt.Errorf("Document with the ID -> %s can't be encoded. Reason - %w", id, err)
+	}
+
+	name := collectionPath + "/" + id
+	if _, err := handleReferenceValue(name); err != nil {
+		return nil, wrapError(ErrInvalidValue, id, "referenceValue", err)
+	}
+	if encodedName, found := encodedDoc[documentNameKey]; found && encodedName != name {
+		return nil, newError(ErrInvalidValue, id, "", fmt.Sprintf("Document name -> %s does not match its key in the collection", encodedName))
+	}
+
+	// Timestamps of the document are managed by Firestore, so they are not the part of the write.
+	update := map[string]interface{}{
+		documentNameKey: name,
+		"fields": encodedDoc["fields"],
+	}
+
+	write := map[string]interface{}{"update": update}
+	if opts.UpdateMask == UpdateMaskFields {
+		// Document metadata is not the part of the fields, so it's not masked.
+		encodedFields, _ := encodedDoc["fields"].(map[string]interface{})
+		literalFields := make(map[string]interface{}, len(encodedFields))
+		for k := range encodedFields {
+			literalFields[k] = fields[k]
+		}
+		write["updateMask"] = map[string]interface{}{"fieldPaths": updateMaskPaths(docMap, literalFields, []string{})}
+	}
+	if len(transforms) > 0 {
+		write["updateTransforms"] = transforms
+	}
+	switch opts.Precondition {
+	case PreconditionExists:
+		write["currentDocument"] = map[string]interface{}{"exists": true}
+	case PreconditionMissing:
+		write["currentDocument"] = map[string]interface{}{"exists": false}
+	}
+
+	return write, nil
+}
+
+// Builds the 'commit' or 'batchWrite' request bodies from the plain JSON collection - {"{document_id}": {...}}.
+// Writes are split into multiple bodies, in case, if there are more than 500 of them.
+func BuildWriteBodies(collection map[string]interface{}, opts Options) ([]interface{}, error) {
+	if opts.CollectionPath == "" {
+		return nil, newError(ErrInvalidUsage, "", "", "Collection path is required, in order to build the names of the documents to be written")
+	}
+
+	collectionPath, err := handleCollectionPath(opts.CollectionPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(collection) == 0 {
+		return nil, newError(ErrInvalidPayload, "", "", "Collection provided does not contain any documents")
+	}
+
+	// IDs are sorted, so the writes are generated in the same order each time.
+	ids := make([]string, 0, len(collection))
+	for id := range collection {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	bodies := []interface{}{}
+	writes := []interface{}{}
+	for _, id := range ids {
+		write, err := buildUpdateWrite(id, collection[id], collectionPath, &opts)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, write)
+
+		if len(writes) == maxWritesPerRequest {
+			bodies = append(bodies, map[string]interface{}{"writes": writes})
+			writes = []interface{}{}
+		}
+	}
+
+	if len(writes) > 0 {
+		bodies = append(bodies, map[string]interface{}{"writes": writes})
+	}
+
+	return bodies, nil
+}
@@ -1,7 +1,8 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"os"
 
 	"github.com/mvksxm/firestore-json-convert/cmd"
 	// "github.com/mvksxm/firestore-json-convert/engine"
@@ -9,7 +10,8 @@ import (
 
 func main() {
 	if err := cmd.RootCmd.Execute(); err != nil {
-		log.Fatalln(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(cmd.ExitCode(err))
 	}
 
 	// Commented strings for testing purposes.
@@ -3,11 +3,18 @@ package test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/mvksxm/firestore-json-convert/engine"
+	"github.com/mvksxm/firestore-json-convert/utils"
 )
 
 
@@ -112,4 +119,1132 @@ func TestDecodeFirestore(t *testing.T) {
 		)
 	}
 
-}
\ No newline at end of file
+}
+
+func TestEncodeInvalidPayloads(t *testing.T) {
+
+	invalidPayloads := map[string]map[string]interface{}{
+		"reference_not_document": {"ref": map[string]interface{}{"$reference": "projects/demo/databases/(default)/documents/customers"}},
+		"reference_empty_id": {"ref": map[string]interface{}{"$reference": "projects/demo/databases/(default)/documents/customers/"}},
+		"reference_bad_prefix": {"ref": map[string]interface{}{"$reference": "customers/alice"}},
+		"geo_point_latitude_range": {"loc": map[string]interface{}{"$geoPoint": map[string]interface{}{"latitude": 91.0, "longitude": 0.0}}},
+		"integer_hint_out_of_range": {"id": map[string]interface{}{"$integer": "9223372036854775808"}},
+		"double_tag_not_number": {"ratio": map[string]interface{}{"$double": "half"}},
+		"string_hint_not_string": {"token": map[string]interface{}{"$string": 1.0}},
+		"timestamp_hint_invalid": {"expiresAt": map[string]interface{}{"$timestamp": "tomorrow"}},
+		"vector_empty": {"embedding": map[string]interface{}{"$vector": []interface{}{}}},
+		"vector_not_number": {"embedding": map[string]interface{}{"$vector": []interface{}{0.5, "1"}}},
+		"geo_point_longitude_range": {"loc": map[string]interface{}{"$geoPoint": map[string]interface{}{"latitude": 0.0, "longitude": -180.5}}},
+		"nested_array": {"matrix": []interface{}{[]interface{}{json.Number("1")}}},
+		"reserved_field_name": {"profile": map[string]interface{}{"__name__": "alice"}},
+		"empty_field_name": {"": "empty"},
+		"field_name_too_long": {strings.Repeat("a", 1501): true},
+		"map_too_deep": {"root": nestMap(20)},
+	}
+
+	for k, v := range invalidPayloads {
+		processor := engine.NewProcessor(v)
+		if _, err := processor.ConvertToFirestore(); err == nil {
+			t.Errorf("Encoding of the invalid payload was expected to fail, but no error was returned. (Test Id #%s)", k)
+		}
+	}
+}
+
+func TestEncodeGeoPointShapes(t *testing.T) {
+	var plainPl map[string]interface{}
+	rawPayload := `{"loc": {"latitude": 52.52, "longitude": 13.405}, "offset": {"latitude": 120, "longitude": 5}}`
+	if err := json.Unmarshal([]byte(rawPayload), &plainPl); err != nil {
+		t.Fatalf("Error occured, when parsing the test payload. Err: %s", err.Error())
+	}
+
+	encodedPl, err := engine.EncodeToFirestore(plainPl)
+	if err != nil {
+		t.Fatalf("Map with the coordinates out of their ranges was expected to be encoded as a regular map. Err: %s", err.Error())
+	}
+
+	encodedFields := encodedPl["fields"].(map[string]interface{})
+	expectedTypes := map[string]string{"loc": "geoPointValue", "offset": "mapValue"}
+	for k, typeKey := range expectedTypes {
+		if _, found := encodedFields[k].(map[string]interface{})[typeKey]; !found {
+			t.Errorf("Value under the key -> %s was expected to be encoded into the '%s'. Received: %v", k, typeKey, encodedFields[k])
+		}
+	}
+}
+
+
+func TestDecodeTaggedVector(t *testing.T) {
+	tagVectorsOpts := engine.DefaultOptions()
+	tagVectorsOpts.TagVectors = true
+	// Vectors are tagged, when type hints are emitted, so they survive the round trip as well.
+	typeHintsOpts := engine.DefaultOptions()
+	typeHintsOpts.EmitTypeHints = true
+
+	for _, opts := range []engine.Options{tagVectorsOpts, typeHintsOpts} {
+		assertTaggedVectorRoundTrip(t, opts)
+	}
+}
+
+func assertTaggedVectorRoundTrip(t *testing.T, opts engine.Options) {
+	t.Helper()
+	testPayload := getPayloads(false)["7"][0]
+	decodedPl, err := engine.NewProcessorWithOptions(testPayload, opts).ConvertFromFirestore()
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the vector payload. Err: %s", err.Error())
+	}
+
+	// Tagged vector should be encoded back into the exact Firestore vector structure.
+	encodedPl, err := engine.EncodeToFirestore(decodedPl.(map[string]interface{}))
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the tagged vector back. Err: %s", err.Error())
+	}
+
+	vectorFields := encodedPl["fields"].(map[string]interface{})["embedding"].(map[string]interface{})["mapValue"].(map[string]interface{})["fields"].(map[string]interface{})
+	if vectorFields["__type__"].(map[string]interface{})["stringValue"] != "__vector__" {
+		t.Errorf("Tagged vector was not encoded back into the Firestore vector.")
+	}
+}
+
+
+func TestRoundTripNumberTypes(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.PreserveNumberTypes = true
+
+	testPayload := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"wholeDouble": map[string]interface{}{"doubleValue": "3"},
+			"integer": map[string]interface{}{"integerValue": "3"},
+			"double": map[string]interface{}{"doubleValue": "4.75"},
+		},
+	}
+
+	decodedPl, err := engine.DecodeFromFirestoreWithOptions(testPayload, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the payload. Err: %s", err.Error())
+	}
+
+	// Decoded payload goes through the same json representation, as it does, when written to the file.
+	decodedPlByte, err := json.Marshal(decodedPl)
+	if err != nil {
+		t.Fatalf("Error occured, when marshalling the decoded payload. Err: %s", err.Error())
+	}
+	decoder := json.NewDecoder(bytes.NewReader(decodedPlByte))
+	decoder.UseNumber()
+	readPl := map[string]interface{}{}
+	if err := decoder.Decode(&readPl); err != nil {
+		t.Fatalf("Error occured, when unmarshalling the decoded payload. Err: %s", err.Error())
+	}
+
+	encodedPl, err := engine.EncodeToFirestoreWithOptions(readPl, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the payload back. Err: %s", err.Error())
+	}
+
+	encodedFields := encodedPl["fields"].(map[string]interface{})
+	expected := map[string]map[string]interface{}{
+		"wholeDouble": {"doubleValue": "3"},
+		"integer": {"integerValue": "3"},
+		"double": {"doubleValue": "4.75"},
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(encodedFields[k], v) {
+			t.Errorf("Field -> %s was not preserved during the round trip. Expected: %v, received: %v", k, v, encodedFields[k])
+		}
+	}
+}
+
+
+func TestRoundTripTypeHints(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.EmitTypeHints = true
+
+	// Firestore payload, which values would be guessed differently by the encoder without the type hints.
+	testPayload := getPayloads(true)["10"][1]
+	decodedPl, err := engine.DecodeFromFirestoreWithOptions(testPayload, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the payload. Err: %s", err.Error())
+	}
+
+	encodedPl, err := engine.EncodeToFirestoreWithOptions(decodedPl, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the payload back. Err: %s", err.Error())
+	}
+
+	testPlByte, _ := json.Marshal(testPayload)
+	encodedPlByte, _ := json.Marshal(encodedPl)
+	if !bytes.Equal(testPlByte, encodedPlByte) {
+		t.Errorf("Payload was changed during the round trip. Expected: %s, received: %s", testPlByte, encodedPlByte)
+	}
+}
+
+
+func TestEncodeWithSchema(t *testing.T) {
+	schema, err := engine.LoadSchema("./samples/schema/schema_1.json")
+	if err != nil {
+		t.Fatalf("Error occured, when loading the schema. Err: %s", err.Error())
+	}
+
+	opts := engine.DefaultOptions()
+	opts.Schema = schema
+
+	testPayload := map[string]interface{}{
+		"token": "dGVzdAo=",
+		"score": json.Number("3"),
+		"tags": []interface{}{"2024-10-01T12:00:00Z"},
+		"profile": map[string]interface{}{
+			"age": json.Number("29"),
+			"last.login": "2024-10-01T12:00:00Z",
+		},
+		"labels": map[string]interface{}{"env": "prod"},
+		"extra": json.Number("1"),
+	}
+
+	encodedPl, err := engine.EncodeToFirestoreWithOptions(testPayload, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the payload with the schema. Err: %s", err.Error())
+	}
+
+	encodedPlByte, _ := json.Marshal(encodedPl)
+	expected := `{"fields":{"extra":{"integerValue":"1"},"labels":{"mapValue":{"fields":{"env":{"stringValue":"prod"}}}},` +
+		`"profile":{"mapValue":{"fields":{"age":{"integerValue":"29"},"last.login":{"timestampValue":"2024-10-01T12:00:00Z"}}}},` +
+		`"score":{"doubleValue":"3"},"tags":{"arrayValue":{"values":[{"stringValue":"2024-10-01T12:00:00Z"}]}},"token":{"stringValue":"dGVzdAo="}}}`
+	if string(encodedPlByte) != expected {
+		t.Errorf("Payload encoded with the schema is not equal to the intended result. Received: %s", encodedPlByte)
+	}
+
+	invalidPayloads := map[string]map[string]interface{}{
+		"type_mismatch": {"score": "high"},
+		"not_declared": {"unknown": "value"},
+		"nested_mismatch": {"profile": map[string]interface{}{"age": "29"}},
+	}
+
+	for k, v := range invalidPayloads {
+		if _, err := engine.EncodeToFirestoreWithOptions(v, opts); err == nil {
+			t.Errorf("Encoding of the payload, that does not match the schema, was expected to fail. (Test Id #%s)", k)
+		}
+	}
+}
+
+
+func TestInferSchema(t *testing.T) {
+	inferredSchema, err := engine.InferSchema([]string{
+		"./samples/test/test_encode_1.json",
+		"./samples/test/test_decode_4.json",
+		"./samples/test/test_encode_4.json",
+	})
+	if err != nil {
+		t.Fatalf("Error occured, when inferring the schema. Err: %s", err.Error())
+	}
+
+	if inferredSchema.Documents != 3 {
+		t.Errorf("Expected 3 documents to be added to the schema, received: %d", inferredSchema.Documents)
+	}
+
+	if stats := inferredSchema.Fields["profile"]; stats == nil || stats.Optional || stats.Types["map"] != 3 {
+		t.Errorf("Field 'profile' was expected to be a required map in all 3 documents. Received: %+v", stats)
+	}
+
+	if stats := inferredSchema.Fields["tags"]; stats == nil || !stats.Optional || stats.ElementTypes["string"] != 4 || stats.ElementTypes["integer"] != 2 {
+		t.Errorf("Field 'tags' was expected to be an optional array of strings and integers. Received: %+v", stats)
+	}
+
+	manifest := inferredSchema.Manifest()
+	expected := map[string]string{"profile.address.city": "string", "profile.preferences.newsletter": "any", "tags[]": "any"}
+	for k, v := range expected {
+		if manifest[k] != v {
+			t.Errorf("Field -> %s was expected to have the type '%s' in the manifest, received: '%s'", k, v, manifest[k])
+		}
+	}
+
+	// Manifest should be accepted as the schema for the strict encoding.
+	if _, err := engine.NewSchema(manifest); err != nil {
+		t.Errorf("Inferred manifest can't be used as a schema. Err: %s", err.Error())
+	}
+}
+
+
+func TestDocumentMetadata(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.IDKey = "__id__"
+	opts.UpdateTimeKey = "__updated__"
+	opts.CollectionPath = "projects/demo/databases/(default)/documents/users"
+
+	testPayload := getPayloads(false)["11"][0]
+	decodedPl, err := engine.DecodeFromFirestoreWithOptions(testPayload, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the document. Err: %s", err.Error())
+	}
+
+	if decodedPl["__id__"] != "alice" || decodedPl["__updated__"] != "2024-10-02T08:30:00Z" {
+		t.Errorf("Document metadata was not surfaced under the keys configured. Received: %v", decodedPl)
+	}
+
+	encodedPl, err := engine.EncodeToFirestoreWithOptions(decodedPl, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the document back. Err: %s", err.Error())
+	}
+
+	encodedPlByte, _ := json.Marshal(encodedPl)
+	expected := `{"fields":{"email":{"stringValue":"alice@example.com"}},` +
+		`"name":"projects/demo/databases/(default)/documents/users/alice","updateTime":"2024-10-02T08:30:00Z"}`
+	if string(encodedPlByte) != expected {
+		t.Errorf("Encoded document is not equal to the intended result. Received: %s", encodedPlByte)
+	}
+
+	// Document ID can't be turned into the name without the collection path.
+	opts.CollectionPath = ""
+	if _, err := engine.EncodeToFirestoreWithOptions(map[string]interface{}{"__id__": "bob"}, opts); err == nil {
+		t.Errorf("Encoding of the document ID without the collection path was expected to fail.")
+	}
+}
+
+
+func TestDecodeRunQueryResponse(t *testing.T) {
+	payload, err := engine.NewFileIO("./samples/responses/run_query_1.json", "").ReadInput()
+	if err != nil {
+		t.Fatalf("Error occured, when reading the runQuery response. Err: %s", err.Error())
+	}
+
+	expected := map[engine.CollectionFormatMode]string{
+		engine.CollectionFormatMap: `{"alice":{"age":29},"bob":{"age":31}}`,
+		engine.CollectionFormatArray: `[{"age":29},{"age":31}]`,
+	}
+
+	for format, expectedPl := range expected {
+		opts := engine.DefaultOptions()
+		opts.CollectionFormat = format
+
+		decodedPl, err := engine.NewProcessorWithOptions(payload, opts).Convert()
+		if err != nil {
+			t.Errorf("Error occured, when decoding the runQuery response. Err: %s (Test Id #%s)", err.Error(), format)
+			continue
+		}
+
+		decodedPlByte, _ := json.Marshal(decodedPl)
+		if string(decodedPlByte) != expectedPl {
+			t.Errorf("Decoded runQuery response is not equal to the intended result. Received: %s (Test Id #%s)", decodedPlByte, format)
+		}
+	}
+}
+
+
+func TestDecodeResponses(t *testing.T) {
+	readResponses := func(paths ...string) []interface{} {
+		payloads := []interface{}{}
+		for _, path := range paths {
+			payload, err := engine.NewFileIO(path, "").ReadInput()
+			if err != nil {
+				t.Fatalf("Error occured, when reading the response - %s. Err: %s", path, err.Error())
+			}
+			payloads = append(payloads, payload)
+		}
+		return payloads
+	}
+
+	// Paginated listDocuments responses are stitched into a single collection.
+	decodedPl, missing, err := engine.DecodeResponses(
+		readResponses("./samples/responses/list_documents_1.json", "./samples/responses/list_documents_2.json"),
+		engine.DefaultOptions(),
+	)
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the listDocuments responses. Err: %s", err.Error())
+	}
+	if decodedPlByte, _ := json.Marshal(decodedPl); string(decodedPlByte) != `{"alice":{"age":29},"bob":{"age":31}}` || len(missing) != 0 {
+		t.Errorf("Decoded listDocuments responses are not equal to the intended result. Received: %s", decodedPlByte)
+	}
+
+	// Missing documents of the batchGet response are reported separately.
+	decodedPl, missing, err = engine.DecodeResponses(readResponses("./samples/responses/batch_get_1.json"), engine.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the batchGet response. Err: %s", err.Error())
+	}
+	if decodedPlByte, _ := json.Marshal(decodedPl); string(decodedPlByte) != `{"alice":{"age":29}}` {
+		t.Errorf("Decoded batchGet response is not equal to the intended result. Received: %s", decodedPlByte)
+	}
+	if !reflect.DeepEqual(missing, []string{"projects/demo/databases/(default)/documents/users/carol"}) {
+		t.Errorf("Missing documents of the batchGet response were not reported. Received: %v", missing)
+	}
+
+	// The same document in the overlapping pages can't be keyed by its ID twice.
+	_, _, err = engine.DecodeResponses(
+		readResponses("./samples/responses/list_documents_1.json", "./samples/responses/batch_get_1.json"),
+		engine.DefaultOptions(),
+	)
+	if err == nil {
+		t.Errorf("Decoding of the responses with the duplicate document IDs was expected to fail.")
+	}
+}
+
+
+func TestMergeEmptyListDocumentsPages(t *testing.T) {
+	dir := t.TempDir()
+	tokenPage, lastPage := filepath.Join(dir, "page_token.json"), filepath.Join(dir, "page_last.json")
+	os.WriteFile(tokenPage, []byte(`{"nextPageToken": "abc"}`), 0777)
+	os.WriteFile(lastPage, []byte(`{}`), 0777)
+	outputPath := filepath.Join(dir, "users.json")
+
+	inputPaths := []string{"./samples/responses/list_documents_1.json", tokenPage, "./samples/responses/list_documents_2.json", lastPage}
+	c := engine.NewMultipleConverterMerge(inputPaths, outputPath)
+	if err := c.Run(); err != nil {
+		t.Fatalf("Empty pages were expected to be merged. Received: %v", err)
+	}
+	if content, _ := os.ReadFile(outputPath); !strings.Contains(string(content), `"bob"`) {
+		t.Errorf("Documents of the non-empty pages were expected to be merged. Received: %s", content)
+	}
+
+	// Standalone empty object is still an empty plain JSON document.
+	if direction, _ := engine.DetectDirection(map[string]interface{}{}); direction != engine.DirectionEncode {
+		t.Errorf("Empty object was expected to be encoded. Received: %s", direction)
+	}
+
+	// Responses are always decoded in merge mode.
+	opts := engine.DefaultOptions()
+	opts.Direction = engine.DirectionEncode
+	c = engine.NewMultipleConverterMerge(inputPaths, outputPath)
+	c.SetOptions(opts)
+	if err := c.Run(); !errors.Is(err, engine.ErrInvalidUsage) {
+		t.Errorf("Merge with the 'encode' direction was expected to be rejected. Received: %v", err)
+	}
+}
+
+func TestBuildWriteBodies(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.WriteBody = engine.WriteBodyCommit
+	opts.Precondition = engine.PreconditionMissing
+	opts.CollectionPath = "projects/demo/databases/(default)/documents/users"
+
+	collection := map[string]interface{}{
+		"alice": map[string]interface{}{"age": json.Number("29")},
+	}
+
+	bodies, err := engine.BuildWriteBodies(collection, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when building the write bodies. Err: %s", err.Error())
+	}
+
+	bodiesByte, _ := json.Marshal(bodies)
+	expected := `[{"writes":[{"currentDocument":{"exists":false},"update":{"fields":{"age":{"integerValue":"29"}},` +
+		`"name":"projects/demo/databases/(default)/documents/users/alice"},"updateMask":{"fieldPaths":["age"]}}]}]`
+	if string(bodiesByte) != expected {
+		t.Errorf("Write bodies are not equal to the intended result. Received: %s", bodiesByte)
+	}
+
+	// Writes should be split into the chunks of 500.
+	for i := 0; i < 1000; i++ {
+		collection[fmt.Sprintf("user_%d", i)] = map[string]interface{}{"idx": json.Number(fmt.Sprint(i))}
+	}
+
+	bodies, err = engine.BuildWriteBodies(collection, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when building the write bodies. Err: %s", err.Error())
+	}
+
+	if len(bodies) != 3 || len(bodies[2].(map[string]interface{})["writes"].([]interface{})) != 1 {
+		t.Errorf("Writes of 1001 documents were expected to be split into 3 bodies, received: %d", len(bodies))
+	}
+
+	// ID with slashes would point to the document in a subcollection.
+	_, err = engine.BuildWriteBodies(map[string]interface{}{"alice/sub/bob": map[string]interface{}{}}, opts)
+	if !errors.Is(err, engine.ErrInvalidValue) {
+		t.Errorf("Document ID with slashes was expected to be rejected. Received: %v", err)
+	}
+}
+
+
+func TestBuildPatchBody(t *testing.T) {
+	oldDoc := map[string]interface{}{
+		"age": json.Number("29"),
+		"deleted": true,
+		"profile": map[string]interface{}{"first.name": "John", "city": "Berlin"},
+	}
+	newDoc := map[string]interface{}{
+		"age": json.Number("29"),
+		"profile": map[string]interface{}{"first.name": "Johnny", "city": "Berlin", "zip": "10115"},
+	}
+
+	patchBody, err := engine.BuildPatchBody(oldDoc, newDoc, engine.DefaultOptions())
+	if err != nil {
+		t.Fatalf("Error occured, when building the patch body. Err: %s", err.Error())
+	}
+
+	patchBodyByte, _ := json.Marshal(patchBody)
+	expected := `{"fields":{"profile":{"mapValue":{"fields":{"first.name":{"stringValue":"Johnny"},"zip":{"stringValue":"10115"}}}}},` +
+		"\"updateMask\":{\"fieldPaths\":[\"deleted\",\"profile.`first.name`\",\"profile.zip\"]}}"
+	if string(patchBodyByte) != expected {
+		t.Errorf("Patch body is not equal to the intended result. Received: %s", patchBodyByte)
+	}
+
+	// Documents provided should not be modified by the encoding.
+	if _, ok := oldDoc["profile"].(map[string]interface{})["city"].(string); !ok {
+		t.Errorf("Old document was modified, when building the patch body.")
+	}
+}
+
+
+func TestBuildPatchBodyInvalidOptions(t *testing.T) {
+	doc := map[string]interface{}{"age": json.Number("29")}
+
+	opts := engine.DefaultOptions()
+	opts.Direction = engine.DirectionDecode
+	if _, err := engine.BuildPatchBody(doc, doc, opts); !errors.Is(err, engine.ErrInvalidUsage) {
+		t.Errorf("Patch body with the 'decode' direction was expected to be rejected. Received: %v", err)
+	}
+
+	opts = engine.DefaultOptions()
+	opts.Direction = "sideways"
+	if _, err := engine.BuildPatchBody(doc, doc, opts); err == nil {
+		t.Errorf("Patch body with the unsupported direction was expected to be rejected.")
+	}
+}
+
+
+func TestBuildWriteBodiesTransforms(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.WriteBody = engine.WriteBodyCommit
+	opts.CollectionPath = "projects/demo/databases/(default)/documents/users"
+
+	collection := map[string]interface{}{
+		"alice": map[string]interface{}{
+			"updatedAt": "$serverTimestamp",
+			"stats": map[string]interface{}{"visits": map[string]interface{}{"$increment": json.Number("1")}},
+			"tags": map[string]interface{}{"$arrayUnion": []interface{}{"admin"}},
+			"name": "Alice",
+			"profile": map[string]interface{}{"city": "Berlin", "logins": map[string]interface{}{"$maximum": json.Number("3")}},
+		},
+		"bob": map[string]interface{}{"stats": map[string]interface{}{"visits": map[string]interface{}{"$increment": json.Number("2")}}},
+	}
+
+	bodies, err := engine.BuildWriteBodies(collection, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when building the write bodies. Err: %s", err.Error())
+	}
+
+	bodiesByte, _ := json.Marshal(bodies)
+	// Only the literal fields are masked, so the rest of the document is kept for the transforms.
+	expected := `[{"writes":[{"update":{"fields":{"name":{"stringValue":"Alice"},` +
+		`"profile":{"mapValue":{"fields":{"city":{"stringValue":"Berlin"}}}}},"name":"projects/demo/databases/(default)/documents/users/alice"},` +
+		`"updateMask":{"fieldPaths":["name","profile.city"]},` +
+		`"updateTransforms":[{"fieldPath":"profile.logins","maximum":{"integerValue":"3"}},` +
+		`{"fieldPath":"stats.visits","increment":{"integerValue":"1"}},` +
+		`{"appendMissingElements":{"values":[{"stringValue":"admin"}]},"fieldPath":"tags"},` +
+		`{"fieldPath":"updatedAt","setToServerValue":"REQUEST_TIME"}]},` +
+		`{"update":{"fields":{},"name":"projects/demo/databases/(default)/documents/users/bob"},"updateMask":{"fieldPaths":[]},` +
+		`"updateTransforms":[{"fieldPath":"stats.visits","increment":{"integerValue":"2"}}]}]}]`
+	if string(bodiesByte) != expected {
+		t.Errorf("Write bodies are not equal to the intended result. Received: %s", bodiesByte)
+	}
+
+	// Without the mask, the whole document is replaced, before the transforms are applied to it.
+	replaceOpts := opts
+	replaceOpts.UpdateMask = engine.UpdateMaskNone
+	bodies, err = engine.BuildWriteBodies(map[string]interface{}{"bob": collection["bob"]}, replaceOpts)
+	if err != nil {
+		t.Fatalf("Error occured, when building the write bodies. Err: %s", err.Error())
+	}
+	bodiesByte, _ = json.Marshal(bodies)
+	expected = `[{"writes":[{"update":{"fields":{},"name":"projects/demo/databases/(default)/documents/users/bob"},` +
+		`"updateTransforms":[{"fieldPath":"stats.visits","increment":{"integerValue":"2"}}]}]}]`
+	if string(bodiesByte) != expected {
+		t.Errorf("Write bodies without the mask are not equal to the intended result. Received: %s", bodiesByte)
+	}
+
+	invalidDocs := []map[string]interface{}{
+		{"stats": map[string]interface{}{"$increment": json.Number("1"), "total": json.Number("5")}},
+		{"tags": []interface{}{"$serverTimestamp"}},
+		{"stats": map[string]interface{}{"$increment": "one"}},
+	}
+	for i, doc := range invalidDocs {
+		if _, err := engine.BuildWriteBodies(map[string]interface{}{"alice": doc}, opts); err == nil {
+			t.Errorf("Document with the invalid transforms #%d was expected to be rejected.", i)
+		}
+	}
+
+	// Transforms are not allowed outside of the write bodies.
+	if _, err := engine.EncodeToFirestore(collection["alice"].(map[string]interface{})); err == nil {
+		t.Errorf("Payload with the transforms was expected to be rejected outside of the write bodies.")
+	}
+}
+
+
+// Returns the map with the provided amount of the nested levels.
+func nestMap(depth int) map[string]interface{} {
+	nested := map[string]interface{}{"leaf": true}
+	for i := 1; i < depth; i++ {
+		nested = map[string]interface{}{"level": nested}
+	}
+	return nested
+}
+
+
+func TestEncodeConstraintViolations(t *testing.T) {
+	// 20 levels of the nested maps are still allowed.
+	if _, err := engine.EncodeToFirestore(map[string]interface{}{"root": nestMap(19)}); err != nil {
+		t.Errorf("Map with the maximum depth was expected to be encoded. Err: %s", err.Error())
+	}
+
+	payload := map[string]interface{}{
+		"__id__": "alice",
+		"tags": []interface{}{"a", []interface{}{"b"}},
+		"profile": map[string]interface{}{"": "empty", "hinted": map[string]interface{}{"$map": map[string]interface{}{"__key__": true}}},
+	}
+
+	_, err := engine.EncodeToFirestore(payload)
+	if err == nil {
+		t.Fatalf("Encoding of the payload with the constraint violations was expected to fail.")
+	}
+
+	// All the violations should be reported at once.
+	for _, path := range []string{"-> __id__ ", "-> tags[1] ", "-> profile/ ", "-> profile/hinted/$map/__key__ "} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("Violation under the path %s was not reported. Err: %s", path, err.Error())
+		}
+	}
+}
+
+
+func TestEstimateDocumentSize(t *testing.T) {
+	encodedDoc, err := engine.EncodeToFirestore(map[string]interface{}{
+		"name": "Alice",
+		"age": json.Number("29"),
+		"tags": []interface{}{"a", "bc"},
+		"profile": map[string]interface{}{"city": "Berlin"},
+	})
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the document. Err: %s", err.Error())
+	}
+	encodedDoc["name"] = "projects/demo/databases/(default)/documents/users/alice"
+
+	size := engine.EstimateDocumentSize(encodedDoc)
+	// Name (6 + 6 + 16) + fields (11 + 12 + 10 + 20) + document (32).
+	if size.Bytes != 113 {
+		t.Errorf("Estimated size of the document was expected to be 113 bytes, received: %d", size.Bytes)
+	}
+
+	expected := []engine.FieldSize{{Path: "profile", Bytes: 20}, {Path: "age", Bytes: 12}, {Path: "profile.city", Bytes: 12}}
+	if largest := size.Largest(3); !reflect.DeepEqual(largest, expected) {
+		t.Errorf("Biggest field paths are not equal to the intended result. Received: %v", largest)
+	}
+}
+
+
+func TestStdStreams(t *testing.T) {
+	if validated, reason := utils.ValidatePath(utils.StdStreamPath, true); !validated {
+		t.Errorf("Standard input path was expected to be valid. Reason: %s", reason)
+	}
+
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() {
+		os.Stdin, os.Stdout = stdin, stdout
+	}()
+
+	inReader, inWriter, _ := os.Pipe()
+	outReader, outWriter, _ := os.Pipe()
+	os.Stdin, os.Stdout = inReader, outWriter
+
+	inWriter.Write([]byte(`{"age": 29}`))
+	inWriter.Close()
+
+	fileIO := engine.NewFileIO(utils.StdStreamPath, utils.StdStreamPath)
+	payload, err := fileIO.ReadInput()
+	if err != nil {
+		t.Fatalf("Error occured, when reading the standard input. Err: %s", err.Error())
+	}
+
+	encodedPl, err := engine.NewProcessor(payload).ConvertToFirestore()
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the standard input payload. Err: %s", err.Error())
+	}
+	if err := fileIO.WriteOutputChunks([]interface{}{encodedPl, encodedPl}); err != nil {
+		t.Fatalf("Error occured, when writing to the standard output. Err: %s", err.Error())
+	}
+	outWriter.Close()
+
+	output, _ := io.ReadAll(outReader)
+	expected := `{"fields":{"age":{"integerValue":"29"}}}` + "\n" + `{"fields":{"age":{"integerValue":"29"}}}` + "\n"
+	if string(output) != expected {
+		t.Errorf("Standard output is not equal to the intended result. Received: %s", output)
+	}
+}
+
+
+func TestWriteOutputChunksNaming(t *testing.T) {
+	dir := t.TempDir()
+	fileIO := engine.NewFileIO("", filepath.Join(dir, "users_commit.json"))
+	if err := fileIO.WriteOutputChunks([]interface{}{map[string]interface{}{}, map[string]interface{}{}}); err != nil {
+		t.Fatalf("Error occured, when writing the output chunks. Err: %s", err.Error())
+	}
+
+	// Chunks are numbered from 1, their suffix is added before the extension of the output path.
+	for _, name := range []string{"users_commit_1.json", "users_commit_2.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Output chunk -> %s was expected to be written. Err: %s", name, err.Error())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users_commit_0.json")); err == nil {
+		t.Errorf("Output chunks were not expected to be numbered from 0.")
+	}
+}
+
+
+func TestMultipleConverterTree(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	inputFiles := map[string]string{
+		"users/alice.json": `{"age": 29}`,
+		"users/nested/bob.json": `{"age": 31}`,
+		"users/carol_draft.json": `{"age": 40}`,
+		"notes.txt": "not a json",
+	}
+	for relPath, content := range inputFiles {
+		path := filepath.Join(inputDir, relPath)
+		os.MkdirAll(filepath.Dir(path), 0777)
+		os.WriteFile(path, []byte(content), 0777)
+	}
+
+	engine.NewMultipleConverterTree([]string{inputDir}, outputDir, nil, []string{"*_draft.json"}).Run()
+
+	for _, relPath := range []string{"users/alice.json", "users/nested/bob.json"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, relPath))
+		if err != nil {
+			t.Errorf("Output file - %s was expected to be written. Err: %s", relPath, err.Error())
+			continue
+		}
+		if !strings.Contains(string(content), `"integerValue"`) {
+			t.Errorf("Output file - %s does not contain the encoded payload. Received: %s", relPath, content)
+		}
+	}
+
+	for _, relPath := range []string{"users/carol_draft.json", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(outputDir, relPath)); err == nil {
+			t.Errorf("Filtered out file - %s was not expected to be written.", relPath)
+		}
+	}
+}
+
+
+func TestLoadPathMapping(t *testing.T) {
+	mappingDir := t.TempDir()
+	mappingPath := filepath.Join(mappingDir, "mapping.json")
+	os.WriteFile(mappingPath, []byte(`{"users/bob.json": "out/bob.json", "/data/alice.json": "-"}`), 0777)
+
+	inputPaths, outputPaths, err := engine.LoadPathMapping(mappingPath)
+	if err != nil {
+		t.Fatalf("Error occured, when loading the mapping file. Err: %s", err.Error())
+	}
+
+	expectedInputs := []string{"/data/alice.json", filepath.Join(mappingDir, "users/bob.json")}
+	expectedOutputs := []string{"-", filepath.Join(mappingDir, "out/bob.json")}
+	if !reflect.DeepEqual(inputPaths, expectedInputs) || !reflect.DeepEqual(outputPaths, expectedOutputs) {
+		t.Errorf("Mapped paths are not equal to the intended result. Received: %v -> %v", inputPaths, outputPaths)
+	}
+
+	os.WriteFile(mappingPath, []byte(`["users/bob.json"]`), 0777)
+	if _, _, err := engine.LoadPathMapping(mappingPath); err == nil {
+		t.Errorf("Mapping file, that is not a json object, was expected to be rejected.")
+	}
+}
+
+
+func TestDetectDirection(t *testing.T) {
+	payloads := map[string]struct {
+		payload interface{}
+		direction engine.DirectionMode
+	}{
+		"firestore_document": {map[string]interface{}{"fields": map[string]interface{}{"a": map[string]interface{}{"stringValue": "b"}}}, engine.DirectionDecode},
+		"empty_firestore_document": {map[string]interface{}{"name": "projects/demo/databases/(default)/documents/users/alice"}, engine.DirectionDecode},
+		"plain_fields_key": {map[string]interface{}{"fields": []interface{}{"a", "b"}}, engine.DirectionEncode},
+		"plain_fields_map": {map[string]interface{}{"fields": map[string]interface{}{"a": "b"}}, engine.DirectionEncode},
+		"plain_extra_key": {map[string]interface{}{"fields": map[string]interface{}{}, "owner": "alice"}, engine.DirectionEncode},
+		"plain_document": {map[string]interface{}{"age": json.Number("29")}, engine.DirectionEncode},
+		"run_query_response": {[]interface{}{map[string]interface{}{"readTime": "2024-01-01T00:00:00Z"}}, engine.DirectionDecode},
+	}
+
+	for k, v := range payloads {
+		direction, reason := engine.DetectDirection(v.payload)
+		if direction != v.direction {
+			t.Errorf("Direction -> %s was expected, received -> %s, since %s. (Test Id #%s)", v.direction, direction, reason, k)
+		}
+	}
+
+	// Plain document with the 'fields' key is encoded, instead of failing the decoding.
+	plainPl := map[string]interface{}{"fields": map[string]interface{}{"a": "b"}}
+	encodedPl, err := engine.NewProcessor(plainPl).Convert()
+	if err != nil {
+		t.Fatalf("Error occured, when converting the plain payload with the 'fields' key. Err: %s", err.Error())
+	}
+	if encodedPlByte, _ := json.Marshal(encodedPl); string(encodedPlByte) != `{"fields":{"fields":{"mapValue":{"fields":{"a":{"stringValue":"b"}}}}}}` {
+		t.Errorf("Plain payload with the 'fields' key is not encoded as intended. Received: %s", encodedPlByte)
+	}
+}
+
+
+func TestCollectErrors(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.CollectErrors = true
+
+	firestorePl := map[string]interface{}{"fields": map[string]interface{}{
+		"age": map[string]interface{}{"integerValue": "x"},
+		"profile": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"city": map[string]interface{}{"stringValue": json.Number("1")},
+		}}},
+		"tags": map[string]interface{}{"arrayValue": map[string]interface{}{"values": []interface{}{
+			map[string]interface{}{"booleanValue": "yes"},
+		}}},
+	}}
+
+	_, err := engine.DecodeFromFirestoreWithOptions(firestorePl, opts)
+	var multiErr *engine.MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 3 {
+		t.Fatalf("All the 3 decoding errors were expected to be collected. Received: %v", err)
+	}
+
+	plainPl := map[string]interface{}{
+		"__id__": "alice",
+		"ref": map[string]interface{}{"$reference": "customers/alice"},
+		"nested": map[string]interface{}{"ratio": map[string]interface{}{"$double": "half"}},
+	}
+
+	_, err = engine.EncodeToFirestoreWithOptions(plainPl, opts)
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 3 {
+		t.Fatalf("Constraint violation and 2 encoding errors were expected to be collected. Received: %v", err)
+	}
+
+	// Walking stops, once the limit of the errors is reached.
+	opts.MaxErrors = 2
+	_, err = engine.EncodeToFirestoreWithOptions(plainPl, opts)
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 2 || !multiErr.Truncated {
+		t.Errorf("Collected errors were expected to be limited to 2. Received: %v", err)
+	}
+
+	// Only the first error is returned by default.
+	if _, err := engine.EncodeToFirestore(plainPl); errors.As(err, &multiErr) {
+		t.Errorf("Errors were not expected to be collected by default. Received: %v", err)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	firestorePl := map[string]interface{}{"fields": map[string]interface{}{
+		"profile": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"joined": map[string]interface{}{"timestampValue": "yesterday"},
+		}}},
+	}}
+
+	_, err := engine.DecodeFromFirestore(firestorePl)
+	var engineErr *engine.Error
+	if !errors.As(err, &engineErr) || !errors.Is(err, engine.ErrInvalidValue) {
+		t.Fatalf("Invalid timestamp was expected to be reported as the invalid value. Received: %v", err)
+	}
+	if engineErr.Path != "profile/mapValue/fields/joined/timestampValue" || engineErr.FirestoreType != "timestampValue" {
+		t.Errorf("Error was expected to point at the timestamp. Received path: %s, type: %s", engineErr.Path, engineErr.FirestoreType)
+	}
+
+	_, err = engine.EncodeToFirestore(map[string]interface{}{"matrix": []interface{}{[]interface{}{json.Number("1")}}})
+	if !errors.Is(err, engine.ErrConstraintViolation) || errors.Is(err, engine.ErrInvalidValue) {
+		t.Errorf("Nested arrays were expected to be reported as the constraint violation. Received: %v", err)
+	}
+
+	// Kinds are matched through the collected errors and the errors of the write bodies.
+	opts := engine.DefaultOptions()
+	opts.CollectErrors = true
+	_, err = engine.EncodeToFirestoreWithOptions(map[string]interface{}{"ref": map[string]interface{}{"$reference": "users"}}, opts)
+	if !errors.Is(err, engine.ErrInvalidValue) {
+		t.Errorf("Collected error was expected to be matched by its kind. Received: %v", err)
+	}
+
+	opts = engine.DefaultOptions()
+	opts.WriteBody = engine.WriteBodyCommit
+	opts.CollectionPath = "projects/demo/databases/(default)/documents/users"
+	_, err = engine.BuildWriteBodies(map[string]interface{}{"alice": map[string]interface{}{"visits": map[string]interface{}{"$increment": "one"}}}, opts)
+	if !errors.As(err, &engineErr) || engineErr.Kind != engine.ErrInvalidTransform || engineErr.FirestoreType != "increment" {
+		t.Errorf("Invalid transform was expected to be reported with its Firestore transform. Received: %v", err)
+	}
+
+	_, err = engine.NewFileIO("./samples/missing.json", "").ReadInput()
+	if !errors.As(err, &engineErr) || engineErr.Kind != engine.ErrIO || engineErr.File != "./samples/missing.json" || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Missing file was expected to be reported as the io error. Received: %v", err)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "alice.json"), []byte(`{"age": 29}`), 0777)
+	os.WriteFile(filepath.Join(dir, "bob.json"), []byte(`{"fields": {"age": {"integerValue": "x"}}}`), 0777)
+
+	inputPaths := []string{filepath.Join(dir, "alice.json"), filepath.Join(dir, "bob.json"), filepath.Join(dir, "missing.json")}
+	outputPaths := []string{filepath.Join(dir, "alice_out.json"), filepath.Join(dir, "bob_out.json"), filepath.Join(dir, "missing_out.json")}
+	reportPath := filepath.Join(dir, "report.json")
+
+	c := engine.NewMultipleConverter(inputPaths, outputPaths)
+	c.SetReport(engine.ReportJSON, reportPath)
+	c.Run()
+
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Report was expected to be written. Err: %s", err.Error())
+	}
+	report := engine.RunReport{}
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Report is not a valid json. Err: %s", err.Error())
+	}
+
+	expectedSummary := engine.ReportSummary{Files: 3, Converted: 1, Failed: 1, Skipped: 1, Documents: 1, DurationMs: report.Summary.DurationMs}
+	if report.Mode != "generate" || report.Summary != expectedSummary {
+		t.Errorf("Report summary is not equal to the intended result. Received: %+v", report.Summary)
+	}
+
+	alice, bob, missing := report.Files[0], report.Files[1], report.Files[2]
+	if alice.Status != engine.FileConverted || alice.Direction != engine.DirectionEncode || alice.Output != outputPaths[0] {
+		t.Errorf("Converted file was expected to be reported with its direction and output. Received: %+v", alice)
+	}
+	if bob.Status != engine.FileFailed || len(bob.Errors) != 1 || bob.Errors[0].Kind != engine.ErrInvalidValue || bob.Errors[0].Path != "age/integerValue" {
+		t.Errorf("Failed file was expected to be reported with the path of its error. Received: %+v", bob)
+	}
+	if missing.Status != engine.FileSkipped || len(missing.Errors) != 1 {
+		t.Errorf("File with the invalid path was expected to be reported as skipped. Received: %+v", missing)
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "alice.json"), []byte(`{"age": 29}`), 0777)
+	os.WriteFile(filepath.Join(dir, "bob.json"), []byte(`{"fields": {"age": {"integerValue": "x"}}}`), 0777)
+
+	alice, bob := filepath.Join(dir, "alice.json"), filepath.Join(dir, "bob.json")
+	aliceOut, bobOut := filepath.Join(dir, "alice_out.json"), filepath.Join(dir, "bob_out.json")
+
+	if err := engine.NewMultipleConverter([]string{alice}, []string{aliceOut}).Run(); err != nil {
+		t.Errorf("Run was expected to succeed. Received: %v", err)
+	}
+
+	err := engine.NewMultipleConverter([]string{alice, bob}, []string{aliceOut, bobOut}).Run()
+	if !errors.Is(err, engine.ErrPartialFailure) {
+		t.Errorf("Run was expected to be partially failed. Received: %v", err)
+	}
+
+	err = engine.NewMultipleConverter([]string{bob}, []string{bobOut}).Run()
+	if !errors.Is(err, engine.ErrAllFailed) {
+		t.Errorf("Run was expected to be failed. Received: %v", err)
+	}
+
+	opts := engine.DefaultOptions()
+	opts.Direction = "sideways"
+	c := engine.NewMultipleConverter([]string{alice}, []string{aliceOut})
+	c.SetOptions(opts)
+	if err := c.Run(); !errors.Is(err, engine.ErrInvalidUsage) {
+		t.Errorf("Invalid options were expected to be reported as the usage error. Received: %v", err)
+	}
+
+	// Files after the first failure are not converted in fail-fast mode.
+	os.Remove(aliceOut)
+	c = engine.NewMultipleConverter([]string{bob, alice}, []string{bobOut, aliceOut})
+	c.SetFailFast(true)
+	if err := c.Run(); !errors.Is(err, engine.ErrAllFailed) {
+		t.Errorf("Run was expected to be stopped at the first failure. Received: %v", err)
+	}
+	if _, err := os.Stat(aliceOut); err == nil {
+		t.Errorf("File after the first failure was not expected to be converted.")
+	}
+	if aborted := c.Report().Files[0]; aborted.Status != engine.FileSkipped || aborted.Errors[0].Kind != engine.ErrAborted {
+		t.Errorf("File after the first failure was expected to be reported as aborted. Received: %+v", aborted)
+	}
+}
+
+func TestDecodeEmptyDocument(t *testing.T) {
+	name := "projects/demo/databases/(default)/documents/users/alice"
+
+	decoded, err := engine.DecodeFromFirestore(map[string]interface{}{"name": name, "updateTime": "2024-10-02T08:30:00Z"})
+	if err != nil || len(decoded) != 0 {
+		t.Errorf("Document without fields was expected to be decoded into an empty object. Received: %v, err: %v", decoded, err)
+	}
+
+	// Keys, other than the document metadata, are plain fields, so the payload is not an empty Firestore document.
+	plainPl := map[string]interface{}{"name": name, "age": json.Number("3")}
+	if _, err := engine.DecodeFromFirestore(plainPl); err == nil {
+		t.Errorf("Document name with the plain fields was not expected to be decoded.")
+	}
+
+	is := engine.NewInferredSchema()
+	for _, payload := range []map[string]interface{}{{"name": name}, plainPl} {
+		if err := is.AddDocument(payload); err != nil {
+			t.Errorf("Document was expected to be added to the schema. Err: %s", err.Error())
+		}
+	}
+	if is.Documents != 2 {
+		t.Errorf("Both documents were expected to be added to the schema. Received: %d", is.Documents)
+	}
+
+	// Invalid Firestore document is not treated as a plain JSON one with the 'fields' key.
+	invalidPl := map[string]interface{}{"fields": map[string]interface{}{"age": map[string]interface{}{"integerValue": "x"}}}
+	if err := is.AddDocument(invalidPl); !errors.Is(err, engine.ErrInvalidPayload) {
+		t.Errorf("Invalid Firestore document was expected to be rejected. Received: %v", err)
+	}
+	if _, found := is.Fields["fields"]; found || is.Documents != 2 {
+		t.Errorf("Invalid Firestore document was not expected to be added to the schema.")
+	}
+}
+func TestDecodeEmptyMapAndArray(t *testing.T) {
+	var testPayload map[string]interface{}
+	rawPayload := `{"fields": {"profile": {"mapValue": {}}, "tags": {"arrayValue": {}}}}`
+	if err := json.Unmarshal([]byte(rawPayload), &testPayload); err != nil {
+		t.Fatalf("Error occured, when parsing the test payload. Err: %s", err.Error())
+	}
+
+	decodedPl, err := engine.DecodeFromFirestore(testPayload)
+	if err != nil {
+		t.Fatalf("Map and array without 'fields'/'values' were expected to be decoded as empty ones. Err: %s", err.Error())
+	}
+
+	decodedPlByte, _ := json.Marshal(decodedPl)
+	if expected := `{"profile":{},"tags":[]}`; string(decodedPlByte) != expected {
+		t.Errorf("Decoded payload is not equal to the intended result. Received: %s", decodedPlByte)
+	}
+}
+
+
+func TestEncodeWholeDoubles(t *testing.T) {
+	plainPl := map[string]interface{}{
+		"whole": json.Number("3e2"),
+		"huge": json.Number("1e30"),
+		"negativeHuge": json.Number("-1e30"),
+		// Same values, spelled without the exponent, are encoded the same way.
+		"hugeLiteral": json.Number("1000000000000000000000000000000"),
+		"negativeHugeLiteral": json.Number("-1000000000000000000000000000000"),
+		"overflowLiteral": json.Number("9223372036854775808"),
+		"overflowFraction": json.Number("9223372036854775808.0"),
+	}
+
+	encodedPl, err := engine.EncodeToFirestore(plainPl)
+	if err != nil {
+		t.Fatalf("Error occured, when encoding the payload. Err: %s", err.Error())
+	}
+
+	encodedFields := encodedPl["fields"].(map[string]interface{})
+	expectedTypes := map[string]string{
+		"whole": "integerValue", "huge": "doubleValue", "negativeHuge": "doubleValue", "hugeLiteral": "doubleValue",
+		"negativeHugeLiteral": "doubleValue", "overflowLiteral": "doubleValue", "overflowFraction": "doubleValue",
+	}
+	for k, typeKey := range expectedTypes {
+		if _, found := encodedFields[k].(map[string]interface{})[typeKey]; !found {
+			t.Errorf("Value under the key -> %s was expected to be encoded into the '%s'. Received: %v", k, typeKey, encodedFields[k])
+		}
+	}
+	if !reflect.DeepEqual(encodedFields["huge"], encodedFields["hugeLiteral"]) {
+		t.Errorf("Both spellings of the number were expected to be encoded the same way. Received: %v, %v", encodedFields["huge"], encodedFields["hugeLiteral"])
+	}
+}
+
+func TestDoubleSpellings(t *testing.T) {
+	decodeDouble := func(val string) error {
+		_, err := engine.DecodeFromFirestore(map[string]interface{}{"fields": map[string]interface{}{
+			"ratio": map[string]interface{}{"doubleValue": val},
+		}})
+		return err
+	}
+
+	for _, val := range []string{"NaN", "Infinity", "-Infinity", "1.5", "-.5", "2e-3", "42"} {
+		if err := decodeDouble(val); err != nil {
+			t.Errorf("Double -> %s was expected to be decoded. Err: %s", val, err.Error())
+		}
+	}
+
+	// Spellings, accepted by strconv, but not produced by the Firestore API, are rejected.
+	for _, val := range []string{"inf", "+Inf", "nan", "0x1p-2", "1_000", ""} {
+		if err := decodeDouble(val); !errors.Is(err, engine.ErrInvalidValue) {
+			t.Errorf("Double -> '%s' was expected to be rejected as the invalid value. Received: %v", val, err)
+		}
+		hintedPl := map[string]interface{}{"ratio": map[string]interface{}{"$double": val}}
+		if _, err := engine.EncodeToFirestore(hintedPl); !errors.Is(err, engine.ErrInvalidValue) {
+			t.Errorf("Double hint -> '%s' was expected to be rejected as the invalid value. Received: %v", val, err)
+		}
+	}
+}
+
+func TestReadTrailingData(t *testing.T) {
+	dir := t.TempDir()
+	payloadPath := filepath.Join(dir, "payload.json")
+	schemaPath := filepath.Join(dir, "schema.json")
+
+	os.WriteFile(payloadPath, []byte("{\"a\": 1}\n"), 0777)
+	if _, err := engine.NewFileIO(payloadPath, "").ReadInput(); err != nil {
+		t.Errorf("Payload followed by the new line was expected to be read. Err: %s", err.Error())
+	}
+
+	os.WriteFile(payloadPath, []byte(`{"a": 1} garbage`), 0777)
+	if _, err := engine.NewFileIO(payloadPath, "").ReadInput(); !errors.Is(err, engine.ErrInvalidPayload) {
+		t.Errorf("Payload followed by the trailing data was expected to be rejected. Received: %v", err)
+	}
+
+	os.WriteFile(schemaPath, []byte(`{"fields": {"age": "integer"}} {}`), 0777)
+	if _, err := engine.LoadSchema(schemaPath); !errors.Is(err, engine.ErrInvalidUsage) {
+		t.Errorf("Schema followed by the trailing data was expected to be rejected. Received: %v", err)
+	}
+}
+
+func TestReportJoinedErrors(t *testing.T) {
+	dir := t.TempDir()
+	payloadPath := filepath.Join(dir, "matrix.json")
+	os.WriteFile(payloadPath, []byte(`{"matrix": [[1]], "grid": [[2]]}`), 0777)
+
+	c := engine.NewMultipleConverter([]string{payloadPath}, []string{filepath.Join(dir, "matrix_out.json")})
+	if err := c.Run(); !errors.Is(err, engine.ErrAllFailed) {
+		t.Fatalf("Run was expected to be failed. Received: %v", err)
+	}
+
+	// Each violation is reported separately with its own path.
+	paths := []string{}
+	for _, reportErr := range c.Report().Files[0].Errors {
+		if reportErr.Kind != engine.ErrConstraintViolation {
+			t.Errorf("Error was expected to be reported as the constraint violation. Received: %+v", reportErr)
+		}
+		paths = append(paths, reportErr.Path)
+	}
+	if expected := []string{"grid[0]", "matrix[0]"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Violations were expected to be reported one by one. Received: %v", paths)
+	}
+}
+
+func TestReportMissingDocuments(t *testing.T) {
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "report.json")
+
+	c := engine.NewMultipleConverter([]string{"./samples/responses/batch_get_1.json"}, []string{filepath.Join(dir, "users.json")})
+	c.SetReport(engine.ReportJSON, reportPath)
+	if err := c.Run(); err != nil {
+		t.Fatalf("Run was expected to succeed. Err: %s", err.Error())
+	}
+
+	expected := []string{"projects/demo/databases/(default)/documents/users/carol"}
+	if missing := c.Report().Files[0].Missing; !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Missing documents were expected to be reported. Received: %v", missing)
+	}
+
+	content, _ := os.ReadFile(reportPath)
+	if !strings.Contains(string(content), `"missing":["projects/demo/databases/(default)/documents/users/carol"]`) {
+		t.Errorf("Missing documents were expected to be listed in the json report. Received: %s", content)
+	}
+}
+
+func TestRoundTripTransformLiterals(t *testing.T) {
+	opts := engine.DefaultOptions()
+	opts.EmitTypeHints = true
+
+	firestorePl := map[string]interface{}{"fields": map[string]interface{}{
+		"s": map[string]interface{}{"stringValue": "$serverTimestamp"},
+		"m": map[string]interface{}{"mapValue": map[string]interface{}{"fields": map[string]interface{}{
+			"$increment": map[string]interface{}{"integerValue": "5"},
+		}}},
+	}}
+
+	decodedPl, err := engine.DecodeFromFirestoreWithOptions(firestorePl, opts)
+	if err != nil {
+		t.Fatalf("Error occured, when decoding the payload. Err: %s", err.Error())
+	}
+
+	encodedPl, err := engine.EncodeToFirestoreWithOptions(decodedPl, opts)
+	if err != nil {
+		t.Fatalf("Literals, that look like the transform sentinels, were expected to be encoded back. Err: %s", err.Error())
+	}
+	if !reflect.DeepEqual(encodedPl, firestorePl) {
+		t.Errorf("Payload was expected to survive the round trip. Received: %v", encodedPl)
+	}
+}
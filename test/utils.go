@@ -1,6 +1,7 @@
 package test
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -46,7 +47,9 @@ func populateTestMap(
 			if err != nil {
 				log.Fatalf("Failed to read the following file -> %s", f.Name())
 			}
-			uErr := json.Unmarshal(byteVal, &payload)
+			decoder := json.NewDecoder(bytes.NewReader(byteVal))
+			decoder.UseNumber()
+			uErr := decoder.Decode(&payload)
 			if uErr != nil {
 				log.Fatalf("An issue occured, when unmarshalling the following file -> %s",filePath)
 			}
@@ -3,11 +3,41 @@ package utils
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/mvksxm/firestore-json-convert/models"
 )
 
+const (
+	// Path, that stands for the standard input, when provided as an input path, and for the standard output otherwise.
+	StdStreamPath = "-"
+)
+
+func IsStdStream(path string) bool {
+	return path == StdStreamPath
+}
+
+// Checks, whether the data is piped into the standard input, so reading it won't wait for the terminal input.
+func IsStdinPiped() bool {
+	stdinInfo, err := os.Stdin.Stat()
+	return err == nil && stdinInfo.Mode() & os.ModeCharDevice == 0
+}
+
+// Checks, whether the path is a glob pattern (e.g. 'data/*/users_*.json').
+func IsGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func IsFile(path string) bool {
+	pathInfo, err := os.Stat(path)
+	return err == nil && !pathInfo.IsDir()
+}
+
+func IsDir(path string) bool {
+	pathInfo, err := os.Stat(path)
+	return err == nil && pathInfo.IsDir()
+}
 
 func ValidatePaths(paths []string, isInput bool, vc chan <- models.StampedPath, wg *sync.WaitGroup) {
 	
@@ -26,6 +56,10 @@ func ValidatePaths(paths []string, isInput bool, vc chan <- models.StampedPath,
 }
 
 func ValidatePath(path string, isInput bool) (bool, string) {
+
+	if IsStdStream(path) {
+		return true, ""
+	}
 	
 	// Check if paths provided are not dirs
 	pathInfo, err := os.Stat(path)